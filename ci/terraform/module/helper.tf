@@ -24,10 +24,20 @@ resource "cloudfoundry_app" "helper" {
     "AWS_SECRET_ACCESS_KEY" = var.helper_aws_secret_access_key
 
     "BROKER_URL"                         = "https://${local.csb_route}"
+    "BROKER_USERNAME"                    = "broker"
+    "BROKER_PASSWORD"                    = random_password.csb_app_password.result
     "CG_PLATFORM_NOTIFICATION_TOPIC_ARN" = var.email_notification_topic_arn
     "HOST"                               = local.helper_route
   }
 
+  # Readiness uses /healthz, not /readyz: a broker or AWS outage must not pull
+  # the route, or SES reputation alarms stop being enforced and the cached docs
+  # can't be served.
+  health_check_type                    = "http"
+  health_check_http_endpoint           = "/healthz"
+  readiness_health_check_type          = "http"
+  readiness_health_check_http_endpoint = "/healthz"
+
   routes = [{
     route = local.helper_route
   }]
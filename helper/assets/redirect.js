@@ -0,0 +1,13 @@
+// The docs used to be one page. Send links to its anchors, like
+// /#service-aws-ses, to the service page that now has the anchor.
+(function () {
+  var map = document.getElementById("cg-anchor-map");
+  if (!map || location.hash.length < 2) {
+    return;
+  }
+  var anchors = JSON.parse(map.textContent);
+  var page = anchors[decodeURIComponent(location.hash.slice(1))];
+  if (page) {
+    location.replace(page + location.hash);
+  }
+})();
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/user"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awscfg "github.com/aws/aws-sdk-go-v2/config"
+	awsses "github.com/aws/aws-sdk-go-v2/service/ses"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+
+	"github.com/cloud-gov/csb/helper/internal/brokerpaks/ses"
+	"github.com/cloud-gov/csb/helper/internal/config"
+	"github.com/cloud-gov/csb/helper/internal/logging"
+)
+
+const usage = `usage: helper [command] [flags]
+
+Commands:
+  serve                      serve the helper (default)
+  verify-sns <message.json>  verify the signature and topic of a captured SNS message
+  pause <configuration-set>  disable sending on an SES configuration set
+  resume <configuration-set> re-enable sending on an SES configuration set
+
+Run "helper <command> -h" for the flags of each command.`
+
+// run dispatches to the command named by the first argument, or to serve if
+// there is none.
+// It is separate from main so it can return errors conventionally and main
+// can handle them all in one place.
+func run(ctx context.Context, out io.Writer, args []string) error {
+	cmd := "serve"
+	if len(args) > 0 && len(args[0]) > 0 && args[0][0] != '-' {
+		cmd, args = args[0], args[1:]
+	}
+	switch cmd {
+	case "serve":
+		return serve(ctx, out, args)
+	case "verify-sns":
+		return verifySNS(ctx, out, args)
+	case "pause":
+		return setSending(ctx, out, "pause", args)
+	case "resume":
+		return setSending(ctx, out, "resume", args)
+	case "help":
+		fmt.Fprintln(out, usage)
+		return nil
+	default:
+		return fmt.Errorf("unknown command '%v'\n%v", cmd, usage)
+	}
+}
+
+// verifySNS runs [ses.VerifySNSMessage] on a captured SNS message, as the
+// reputation alarm endpoint would, and explains any failure.
+func verifySNS(ctx context.Context, out io.Writer, args []string) error {
+	flags := flag.NewFlagSet("helper verify-sns", flag.ContinueOnError)
+	flags.SetOutput(out)
+	topicARN := flags.String("topic-arn", "", "the ARN of the topic the message must come from; defaults to CG_PLATFORM_NOTIFICATION_TOPIC_ARN")
+	certDomain := flags.String("cert-domain", "", "the domain the signing certificate must be served from; defaults to SNS_SIGNING_CERT_DOMAIN, or else the host of AWS_ENDPOINT_URL_SNS, or else the SNS endpoint for the AWS region")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: helper verify-sns [flags] <message.json>")
+	}
+
+	// Only read the configuration for values the flags don't supply, and only
+	// the keys this command needs, so it works offline without the rest.
+	if *topicARN == "" || *certDomain == "" {
+		vals, err := config.Sources()
+		if err != nil {
+			return fmt.Errorf("loading CSB Helper config: %w", err)
+		}
+		_, domain, err := config.ParseAWS(vals)
+		if err != nil {
+			return fmt.Errorf("loading CSB Helper config: %w", err)
+		}
+		if *topicARN == "" {
+			*topicARN = vals["CG_PLATFORM_NOTIFICATION_TOPIC_ARN"].Value
+		}
+		if *certDomain == "" {
+			*certDomain = domain
+		}
+	}
+	if *topicARN == "" {
+		return errors.New("no topic ARN: set --topic-arn or CG_PLATFORM_NOTIFICATION_TOPIC_ARN")
+	}
+	if *certDomain == "" {
+		awsconfig, err := awscfg.LoadDefaultConfig(ctx)
+		if err != nil {
+			return fmt.Errorf("loading AWS config: %w", err)
+		}
+		*certDomain, err = resolveSNSDomain(ctx, awsconfig)
+		if err != nil {
+			return fmt.Errorf("resolving SNS endpoint: %w", err)
+		}
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	msg, err := ses.UnmarshalMessage(f)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "message %v of type %v from topic %v\n", msg.MessageId, msg.Type, msg.TopicArn)
+	if err := ses.VerifySNSMessage(ctx, msg, *certDomain, *topicARN); err != nil {
+		return fmt.Errorf("message is not valid: %w\n%v", err, ses.ExplainVerificationError(err))
+	}
+	fmt.Fprintln(out, "message is valid")
+	return nil
+}
+
+// setSending pauses or resumes sending on an SES configuration set through
+// [ses.Enforcer], so manual changes are audited like automatic ones.
+func setSending(ctx context.Context, out io.Writer, action string, args []string) error {
+	flags := flag.NewFlagSet("helper "+action, flag.ContinueOnError)
+	flags.SetOutput(out)
+	reason := flags.String("reason", "", "why sending is being changed, for the audit log (required)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: helper %v --reason <reason> <configuration-set>", action)
+	}
+	if *reason == "" {
+		return errors.New("--reason is required")
+	}
+	cset := flags.Arg(0)
+
+	vals, err := config.Sources()
+	if err != nil {
+		return fmt.Errorf("loading CSB Helper config: %w", err)
+	}
+	endpoints, _, err := config.ParseAWS(vals)
+	if err != nil {
+		return fmt.Errorf("loading CSB Helper config: %w", err)
+	}
+	awsconfig, err := awscfg.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("loading AWS config: %w", err)
+	}
+	client := awsses.NewFromConfig(awsconfig, func(o *awsses.Options) {
+		if endpoints.SES != "" {
+			o.BaseEndpoint = aws.String(endpoints.SES)
+		}
+	})
+
+	logger := slog.New(logging.NewContextHandler(slog.NewTextHandler(out, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	})))
+	enforcer := ses.Enforcer{Client: client, Logger: logger}
+	if action == "pause" {
+		return enforcer.Pause(ctx, cset, cliActor(), *reason)
+	}
+	return enforcer.Resume(ctx, cset, cliActor(), *reason)
+}
+
+// cliActor identifies the operator running the helper for the audit log.
+func cliActor() string {
+	u, err := user.Current()
+	if err != nil {
+		return "cli:unknown"
+	}
+	return "cli:" + u.Username
+}
+
+// resolveSNSDomain returns the host of the non-FIPS SNS endpoint for the
+// configured AWS region. SNS serves signing certificates from this domain.
+func resolveSNSDomain(ctx context.Context, awsconfig aws.Config) (string, error) {
+	endpoint, err := sns.NewDefaultEndpointResolverV2().ResolveEndpoint(ctx, sns.EndpointParameters{
+		Region:  aws.String(awsconfig.Region),
+		UseFIPS: aws.Bool(false), // This is used to validate the domain of the SigningCertURL, which will be non-FIPS
+	})
+	if err != nil {
+		return "", err
+	}
+	return endpoint.URI.Host, nil
+}
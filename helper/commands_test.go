@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloud-gov/csb/helper/internal/config"
+)
+
+// clearConfig unsets every CSB Helper config source, and sets fake AWS
+// credentials, so the commands run as they would offline with nothing
+// configured. t.Setenv restores the environment after the test.
+func clearConfig(t *testing.T) {
+	t.Helper()
+	for _, k := range config.Keys {
+		t.Setenv(k.Name, "")
+		os.Unsetenv(k.Name)
+	}
+	for _, k := range []string{"VCAP_SERVICES", config.FileEnv} {
+		t.Setenv(k, "")
+		os.Unsetenv(k)
+	}
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "none"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "none"))
+}
+
+// setConfig makes vals the only CSB Helper config the commands can load, or
+// leaves them with none if vals is nil. The
+// values are written to a config file rather than the environment, so the AWS
+// SDK can't read the endpoint overrides itself.
+func setConfig(t *testing.T, vals map[string]string) {
+	t.Helper()
+	clearConfig(t)
+	if vals == nil {
+		return
+	}
+	b, err := json.Marshal(vals)
+	if err != nil {
+		t.Fatal("error encoding config. This is a problem with the test.", err)
+	}
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		t.Fatal("error writing config file. This is a problem with the test.", err)
+	}
+	t.Setenv(config.FileEnv, path)
+}
+
+func TestVerifySNS(t *testing.T) {
+	const topic = "arn:aws:sns:us-east-1:000000000000:platform-notifications"
+	msg, err := json.Marshal(map[string]string{
+		"Type":             "Notification",
+		"MessageId":        "22b80b92-fdea-4c2c-8f9d-bdfb0c7bf324",
+		"TopicArn":         topic,
+		"Message":          "{}",
+		"SignatureVersion": "1",
+		"Signature":        "EXAMPLE",
+		"SigningCertURL":   "https://sns.us-east-1.amazonaws.com/SimpleNotificationService-example.pem",
+	})
+	if err != nil {
+		t.Fatal("error encoding message. This is a problem with the test.", err)
+	}
+	path := filepath.Join(t.TempDir(), "message.json")
+	if err := os.WriteFile(path, msg, 0o600); err != nil {
+		t.Fatal("error writing message. This is a problem with the test.", err)
+	}
+
+	tests := []struct {
+		name    string
+		config  map[string]string
+		env     map[string]string
+		args    []string
+		wantErr string
+	}{
+		{
+			name:    "message file is required",
+			args:    []string{},
+			wantErr: "usage: helper verify-sns",
+		},
+		{
+			name:    "unknown flags are rejected",
+			args:    []string{"--topic", "arn", path},
+			wantErr: "flag provided but not defined: -topic",
+		},
+		{
+			name:    "config must be valid",
+			config:  map[string]string{"SNS_SIGNING_CERT_DOMAIN": "https://sns.example.com/"},
+			args:    []string{path},
+			wantErr: "invalid SNS_SIGNING_CERT_DOMAIN",
+		},
+		{
+			name:    "topic ARN is required",
+			config:  map[string]string{"SNS_SIGNING_CERT_DOMAIN": "sns.example.com"},
+			args:    []string{path},
+			wantErr: "no topic ARN",
+		},
+		{
+			name:    "topic ARN and cert domain come from config",
+			config:  map[string]string{"CG_PLATFORM_NOTIFICATION_TOPIC_ARN": topic, "SNS_SIGNING_CERT_DOMAIN": "sns.example.com"},
+			args:    []string{path},
+			wantErr: "wanted sns.example.com, got sns.us-east-1.amazonaws.com",
+		},
+		{
+			name:    "cert domain defaults to the host of AWS_ENDPOINT_URL_SNS",
+			config:  map[string]string{"CG_PLATFORM_NOTIFICATION_TOPIC_ARN": topic, "AWS_ENDPOINT_URL_SNS": "http://localhost:4566"},
+			args:    []string{path},
+			wantErr: "wanted localhost:4566, got sns.us-east-1.amazonaws.com",
+		},
+		{
+			name:    "cert domain flag overrides config",
+			config:  map[string]string{"CG_PLATFORM_NOTIFICATION_TOPIC_ARN": topic, "SNS_SIGNING_CERT_DOMAIN": "sns.example.com"},
+			args:    []string{"--cert-domain", "flag.example.com", path},
+			wantErr: "wanted flag.example.com, got sns.us-east-1.amazonaws.com",
+		},
+		{
+			name:    "flags alone are enough",
+			args:    []string{"--topic-arn", topic, "--cert-domain", "flag.example.com", path},
+			wantErr: "wanted flag.example.com, got sns.us-east-1.amazonaws.com",
+		},
+		{
+			name:    "cert domain defaults to the SNS endpoint for the AWS region",
+			env:     map[string]string{"AWS_REGION": "us-west-2"},
+			args:    []string{"--topic-arn", topic, path},
+			wantErr: "wanted sns.us-west-2.amazonaws.com, got sns.us-east-1.amazonaws.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setConfig(t, tt.config)
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			var out bytes.Buffer
+			err := run(context.Background(), &out, append([]string{"verify-sns"}, tt.args...))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestSetSending(t *testing.T) {
+	tests := []struct {
+		name   string
+		config map[string]string
+		// env is set after the config. The fake SES endpoint's URL replaces
+		// "{{endpoint}}" in config and env values.
+		env         map[string]string
+		args        []string
+		wantErr     string
+		wantEnabled string
+	}{
+		{
+			name:    "reason is required",
+			args:    []string{"pause", "ExampleSet"},
+			wantErr: "--reason is required",
+		},
+		{
+			name:    "configuration set is required",
+			args:    []string{"resume", "--reason", "test"},
+			wantErr: "usage: helper resume",
+		},
+		{
+			name:    "config must be valid",
+			config:  map[string]string{"AWS_ENDPOINT_URL_SES": "not a url"},
+			args:    []string{"pause", "--reason", "test", "ExampleSet"},
+			wantErr: "loading CSB Helper config",
+		},
+		{
+			name:        "pause disables sending through AWS_ENDPOINT_URL_SES",
+			config:      map[string]string{"AWS_ENDPOINT_URL_SES": "{{endpoint}}"},
+			args:        []string{"pause", "--reason", "test", "ExampleSet"},
+			wantEnabled: "false",
+		},
+		{
+			name:        "resume enables sending through AWS_ENDPOINT_URL_SES",
+			config:      map[string]string{"AWS_ENDPOINT_URL_SES": "{{endpoint}}"},
+			args:        []string{"resume", "--reason", "test", "ExampleSet"},
+			wantEnabled: "true",
+		},
+		{
+			name:        "no CSB Helper config is needed",
+			env:         map[string]string{"AWS_ENDPOINT_URL": "{{endpoint}}"},
+			args:        []string{"pause", "--reason", "test", "ExampleSet"},
+			wantEnabled: "false",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got url.Values
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := r.ParseForm(); err != nil {
+					t.Errorf("parsing SES request: %v", err)
+				}
+				got = r.PostForm
+				w.Header().Set("Content-Type", "text/xml")
+				w.Write([]byte(`<UpdateConfigurationSetSendingEnabledResponse xmlns="http://ses.amazonaws.com/doc/2010-12-01/"><ResponseMetadata><RequestId>example</RequestId></ResponseMetadata></UpdateConfigurationSetSendingEnabledResponse>`))
+			}))
+			defer srv.Close()
+			endpoint := func(vals map[string]string) map[string]string {
+				if vals == nil {
+					return nil
+				}
+				out := map[string]string{}
+				for k, v := range vals {
+					out[k] = strings.ReplaceAll(v, "{{endpoint}}", srv.URL)
+				}
+				return out
+			}
+			setConfig(t, endpoint(tt.config))
+			for k, v := range endpoint(tt.env) {
+				t.Setenv(k, v)
+			}
+
+			var out bytes.Buffer
+			err := run(context.Background(), &out, tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				if got != nil {
+					t.Errorf("expected no SES request, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected nil error, got %v", err)
+			}
+			if got.Get("Action") != "UpdateConfigurationSetSendingEnabled" || got.Get("ConfigurationSetName") != "ExampleSet" || got.Get("Enabled") != tt.wantEnabled {
+				t.Errorf("expected UpdateConfigurationSetSendingEnabled of ExampleSet with Enabled=%v, got %v", tt.wantEnabled, got)
+			}
+			if !strings.Contains(out.String(), "audit: SES configuration set sending status change") {
+				t.Errorf("expected an audit log line, got:\n%v", out.String())
+			}
+		})
+	}
+}
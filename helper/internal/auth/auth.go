@@ -0,0 +1,352 @@
+// Package auth verifies JSON Web Tokens issued by Cloud Foundry UAA.
+//
+// Only RS256, the algorithm UAA signs with, is supported. Signing keys are
+// fetched from the UAA JWKS endpoint (/token_keys) and cached.
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrMalformedToken     = errors.New("auth: malformed token")
+	ErrUnsupportedAlg     = errors.New("auth: unsupported signing algorithm")
+	ErrUnknownKey         = errors.New("auth: unknown signing key")
+	ErrInvalidSignature   = errors.New("auth: invalid signature")
+	ErrExpired            = errors.New("auth: token expired")
+	ErrNotYetValid        = errors.New("auth: token not yet valid")
+	ErrWrongIssuer        = errors.New("auth: unexpected issuer")
+	ErrWrongAudience      = errors.New("auth: unexpected audience")
+	ErrInsufficientScopes = errors.New("auth: insufficient scopes")
+)
+
+// IsTokenError reports whether err means the token itself was rejected, rather
+// than that it couldn't be checked, like when the signing keys can't be
+// fetched.
+func IsTokenError(err error) bool {
+	for _, target := range []error{
+		ErrMalformedToken, ErrUnsupportedAlg, ErrUnknownKey, ErrInvalidSignature,
+		ErrExpired, ErrNotYetValid, ErrWrongIssuer, ErrWrongAudience,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopeAdmin is the UAA scope of Cloud Foundry administrators, who can use the
+// helper's operator APIs.
+const ScopeAdmin = "cloud_controller.admin"
+
+const (
+	// leeway allows for clock skew between UAA and the helper when checking exp
+	// and nbf.
+	leeway = 30 * time.Second
+	// keysTTL is how long fetched signing keys are trusted before they are
+	// fetched again.
+	keysTTL = time.Hour
+	// minRefresh limits how often an unknown key ID or a failed fetch can make
+	// the verifier fetch keys, so forged tokens or an outage can't make it
+	// hammer UAA.
+	minRefresh = time.Minute
+)
+
+// Claims are the claims of a verified UAA token that the helper uses.
+type Claims struct {
+	Subject   string    `json:"sub"`
+	Issuer    string    `json:"iss"`
+	Audience  audience  `json:"aud"`
+	Scopes    []string  `json:"scope"`
+	UserID    string    `json:"user_id"`
+	UserName  string    `json:"user_name"`
+	ClientID  string    `json:"client_id"`
+	ExpiresAt timestamp `json:"exp"`
+	NotBefore timestamp `json:"nbf"`
+	IssuedAt  timestamp `json:"iat"`
+}
+
+// HasScopes reports whether c has every one of scopes.
+func (c *Claims) HasScopes(scopes ...string) bool {
+	for _, s := range scopes {
+		if !slices.Contains(c.Scopes, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// Actor identifies who the token was issued to, for audit logs: the user name
+// for user tokens, or the client ID for client credentials tokens.
+func (c *Claims) Actor() string {
+	if c.UserName != "" {
+		return "uaa:" + c.UserName
+	}
+	return "uaa-client:" + c.ClientID
+}
+
+// audience is the aud claim, which may be a single string or an array.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+	var l []string
+	if err := json.Unmarshal(b, &l); err != nil {
+		return err
+	}
+	*a = l
+	return nil
+}
+
+// timestamp is a NumericDate claim: seconds since the Unix epoch, possibly
+// fractional.
+type timestamp struct{ time.Time }
+
+func (t *timestamp) UnmarshalJSON(b []byte) error {
+	var f float64
+	if err := json.Unmarshal(b, &f); err != nil {
+		return err
+	}
+	sec := int64(f)
+	t.Time = time.Unix(sec, int64((f-float64(sec))*1e9))
+	return nil
+}
+
+// Verifier verifies tokens signed by the keys at a JWKS URL.
+type Verifier struct {
+	jwksURL  string
+	issuer   string
+	audience string
+	client   *http.Client
+
+	// fetching holds a token while keys are fetched, so concurrent requests
+	// wait for one fetch rather than starting their own.
+	fetching chan struct{}
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+	failedAt  time.Time
+	fetchErr  error
+}
+
+// NewVerifier returns a Verifier for tokens signed by the keys at jwksURL, like
+// "https://uaa.fr.cloud.gov/token_keys". If issuer or audience is empty, that
+// claim is not checked.
+func NewVerifier(client *http.Client, jwksURL string, issuer string, audience string) *Verifier {
+	return &Verifier{
+		jwksURL:  jwksURL,
+		issuer:   issuer,
+		audience: audience,
+		client:   client,
+		fetching: make(chan struct{}, 1),
+	}
+}
+
+// Verify checks the signature and claims of the compact-serialized JWT token
+// and returns its claims.
+func (v *Verifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("%w: '%v'", ErrUnsupportedAlg, header.Alg)
+	}
+
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: signature: %w", ErrMalformedToken, err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	var c Claims
+	if err := decodeSegment(parts[1], &c); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if c.ExpiresAt.IsZero() || now.After(c.ExpiresAt.Add(leeway)) {
+		return nil, ErrExpired
+	}
+	if !c.NotBefore.IsZero() && now.Before(c.NotBefore.Add(-leeway)) {
+		return nil, ErrNotYetValid
+	}
+	if v.issuer != "" && c.Issuer != v.issuer {
+		return nil, fmt.Errorf("%w: '%v'", ErrWrongIssuer, c.Issuer)
+	}
+	if v.audience != "" && !slices.Contains(c.Audience, v.audience) {
+		return nil, fmt.Errorf("%w: %v", ErrWrongAudience, []string(c.Audience))
+	}
+	return &c, nil
+}
+
+func decodeSegment(seg string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrMalformedToken, err)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("%w: %w", ErrMalformedToken, err)
+	}
+	return nil
+}
+
+// key returns the signing key with ID kid, fetching keys if they are stale or
+// kid is unknown. v.mu is not held during the fetch, so requests with cached
+// keys aren't held up by a slow UAA.
+func (v *Verifier) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	if key, done, err := v.cachedKey(kid); done {
+		return key, err
+	}
+
+	select {
+	case v.fetching <- struct{}{}:
+		defer func() { <-v.fetching }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	// Another request may have fetched the keys while this one waited.
+	if key, done, err := v.cachedKey(kid); done {
+		return key, err
+	}
+
+	keys, err := v.fetchKeys(ctx)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err != nil {
+		if ctx.Err() == nil {
+			v.failedAt, v.fetchErr = time.Now(), err
+		}
+		if key, ok := v.keys[kid]; ok {
+			// Keep using a known key if UAA is briefly unreachable.
+			return key, nil
+		}
+		return nil, err
+	}
+	v.keys, v.fetchedAt, v.failedAt, v.fetchErr = keys, time.Now(), time.Time{}, nil
+	key, ok := keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: '%v'", ErrUnknownKey, kid)
+	}
+	return key, nil
+}
+
+// cachedKey returns the signing key with ID kid, or the error to return for it,
+// if that can be decided without fetching keys. done is false if the keys must
+// be fetched.
+func (v *Verifier) cachedKey(kid string) (key *rsa.PublicKey, done bool, err error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	key, ok := v.keys[kid]
+	switch {
+	case ok && time.Since(v.fetchedAt) < keysTTL:
+		return key, true, nil
+	case time.Since(v.failedAt) < minRefresh:
+		// Back off after a failed fetch, rather than trying again on every
+		// request.
+		if ok {
+			return key, true, nil
+		}
+		return nil, true, v.fetchErr
+	case !ok && time.Since(v.fetchedAt) < minRefresh:
+		return nil, true, fmt.Errorf("%w: '%v'", ErrUnknownKey, kid)
+	}
+	return nil, false, nil
+}
+
+// fetchKeys gets the RSA signing keys from the JWKS URL, by key ID.
+func (v *Verifier) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.jwksURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching signing keys: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching signing keys: %v responded with status %v", v.jwksURL, resp.StatusCode)
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return nil, fmt.Errorf("decoding signing keys: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("decoding modulus of key '%v': %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("decoding exponent of key '%v': %w", k.Kid, err)
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
+
+type claimsKey struct{}
+
+// WithClaims returns a copy of ctx that carries c.
+func WithClaims(ctx context.Context, c *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, c)
+}
+
+// ClaimsFromContext returns the claims of the request's verified token, or nil
+// if the request was not authenticated.
+func ClaimsFromContext(ctx context.Context) *Claims {
+	c, _ := ctx.Value(claimsKey{}).(*Claims)
+	return c
+}
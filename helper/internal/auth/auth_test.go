@@ -0,0 +1,200 @@
+package auth_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloud-gov/csb/helper/internal/auth"
+)
+
+// uaa stands in for the UAA /token_keys endpoint and signs tokens with its key.
+type uaa struct {
+	*httptest.Server
+	key     *rsa.PrivateKey
+	kid     string
+	fetches atomic.Int32
+}
+
+func newUAA(t *testing.T) *uaa {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := &uaa{key: key, kid: "key-1"}
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u.fetches.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"alg": "RS256",
+				"use": "sig",
+				"kid": u.kid,
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+	t.Cleanup(u.Close)
+	return u
+}
+
+// sign returns a token with claims, signed with key and the given header.
+func sign(t *testing.T, key *rsa.PrivateKey, header map[string]string, claims map[string]any) string {
+	t.Helper()
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(input))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"sub":       "user-guid",
+		"iss":       "https://uaa.example.com/oauth/token",
+		"aud":       []string{"cloud_controller", "csb-helper"},
+		"scope":     []string{"openid", auth.ScopeAdmin},
+		"user_name": "alice@example.com",
+		"client_id": "cf",
+		"iat":       time.Now().Unix(),
+		"exp":       time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestVerify(t *testing.T) {
+	u := newUAA(t)
+	v := auth.NewVerifier(u.Client(), u.URL, "https://uaa.example.com/oauth/token", "csb-helper")
+	header := map[string]string{"alg": "RS256", "kid": "key-1", "typ": "JWT"}
+
+	claims, err := v.Verify(context.Background(), sign(t, u.key, header, validClaims()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.UserName != "alice@example.com" || !claims.HasScopes(auth.ScopeAdmin) {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+	if claims.Actor() != "uaa:alice@example.com" {
+		t.Errorf("unexpected actor %v", claims.Actor())
+	}
+
+	if _, err := v.Verify(context.Background(), sign(t, u.key, header, validClaims())); err != nil {
+		t.Fatal(err)
+	}
+	if n := u.fetches.Load(); n != 1 {
+		t.Errorf("expected keys to be fetched once and cached, got %v fetches", n)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	u := newUAA(t)
+	v := auth.NewVerifier(u.Client(), u.URL, "https://uaa.example.com/oauth/token", "csb-helper")
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := map[string]string{"alg": "RS256", "kid": "key-1"}
+	with := func(k string, val any) map[string]any {
+		c := validClaims()
+		c[k] = val
+		return c
+	}
+
+	cases := []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{name: "not a JWT", token: "abc.def", want: auth.ErrMalformedToken},
+		{name: "alg none", token: sign(t, u.key, map[string]string{"alg": "none", "kid": "key-1"}, validClaims()), want: auth.ErrUnsupportedAlg},
+		{name: "HS256", token: sign(t, u.key, map[string]string{"alg": "HS256", "kid": "key-1"}, validClaims()), want: auth.ErrUnsupportedAlg},
+		{name: "signed by another key", token: sign(t, otherKey, header, validClaims()), want: auth.ErrInvalidSignature},
+		{name: "unknown key ID", token: sign(t, u.key, map[string]string{"alg": "RS256", "kid": "key-2"}, validClaims()), want: auth.ErrUnknownKey},
+		{name: "expired", token: sign(t, u.key, header, with("exp", time.Now().Add(-time.Hour).Unix())), want: auth.ErrExpired},
+		{name: "no expiry", token: sign(t, u.key, header, with("exp", nil)), want: auth.ErrExpired},
+		{name: "not yet valid", token: sign(t, u.key, header, with("nbf", time.Now().Add(time.Hour).Unix())), want: auth.ErrNotYetValid},
+		{name: "wrong issuer", token: sign(t, u.key, header, with("iss", "https://evil.example.com")), want: auth.ErrWrongIssuer},
+		{name: "wrong audience", token: sign(t, u.key, header, with("aud", "cloud_controller")), want: auth.ErrWrongAudience},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := v.Verify(context.Background(), tc.token)
+			if !errors.Is(err, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, err)
+			}
+			if !auth.IsTokenError(err) {
+				t.Errorf("expected %v to be a token error", err)
+			}
+		})
+	}
+}
+
+func TestVerifyJWKSUnavailable(t *testing.T) {
+	var fetches atomic.Int32
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := auth.NewVerifier(down.Client(), down.URL, "", "")
+	for range 2 {
+		_, err = v.Verify(context.Background(), sign(t, key, map[string]string{"alg": "RS256", "kid": "key-1"}, validClaims()))
+		if err == nil || auth.IsTokenError(err) {
+			t.Errorf("expected an error that is not a token error, got %v", err)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("expected a failed fetch not to be retried right away, got %v fetches", n)
+	}
+}
+
+func TestVerifyConcurrentFetch(t *testing.T) {
+	u := newUAA(t)
+	release := make(chan struct{})
+	keys := u.Config.Handler
+	u.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		keys.ServeHTTP(w, r)
+	})
+	v := auth.NewVerifier(u.Client(), u.URL, "", "")
+	token := sign(t, u.key, map[string]string{"alg": "RS256", "kid": "key-1"}, validClaims())
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := v.Verify(context.Background(), token); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := u.fetches.Load(); n != 1 {
+		t.Errorf("expected concurrent requests to share one fetch, got %v fetches", n)
+	}
+}
@@ -0,0 +1,124 @@
+// Package brokerpaks is a registry of the helper functionality for individual
+// brokerpaks.
+//
+// Each brokerpak package registers a [Factory] in an init function, and is
+// linked into the helper with a blank import in main. The helper mounts every
+// enabled brokerpak under /brokerpaks/<name>/.
+package brokerpaks
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+
+	"github.com/cloud-gov/csb/helper/internal/config"
+)
+
+// Deps are the shared dependencies available to every brokerpak.
+type Deps struct {
+	Logger *slog.Logger
+	Config config.Config
+	// AWS is the shared AWS config. Brokerpaks should apply the endpoint
+	// overrides in Config.AWSEndpoints to the clients they create from it.
+	AWS aws.Config
+	// SNSDomain is the domain that SNS signing certificates must be served
+	// from.
+	SNSDomain string
+}
+
+// Brokerpak is the helper functionality for one brokerpak.
+type Brokerpak interface {
+	// Name is the brokerpak's path segment and config name, like "ses".
+	Name() string
+	// Routes returns the brokerpak's handler. It is mounted at
+	// /brokerpaks/<name>/ with /brokerpaks/<name> stripped from the path, so
+	// its patterns look like "POST /reputation-alarm".
+	Routes() http.Handler
+	// Health reports whether the brokerpak can do its job. A nil error means
+	// healthy.
+	Health(ctx context.Context) error
+}
+
+// Factory builds a Brokerpak from the shared dependencies.
+type Factory func(Deps) (Brokerpak, error)
+
+var (
+	mu        sync.Mutex
+	factories = make(map[string]Factory)
+)
+
+// Register makes a brokerpak available by name, and a valid name for the
+// BROKERPAKS config key. It panics if Register is called twice with the same
+// name.
+func Register(name string, f Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, dup := factories[name]; dup {
+		panic("brokerpaks: Register called twice for brokerpak " + name)
+	}
+	factories[name] = f
+	config.RegisterBrokerpak(name)
+}
+
+// Names returns the sorted names of every registered brokerpak.
+func Names() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	return slices.Sorted(maps.Keys(factories))
+}
+
+// Mount builds each brokerpak in enabled and mounts it on mux under
+// /brokerpaks/<name>/. If enabled is nil, every registered brokerpak is
+// mounted. It returns the mounted brokerpaks, or an error if a name is not
+// registered or is repeated, a brokerpak fails to build, or its path is already
+// taken on mux. Every name is checked before anything is mounted.
+func Mount(mux *http.ServeMux, deps Deps, enabled []string) ([]Brokerpak, error) {
+	if enabled == nil {
+		enabled = Names()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for i, name := range enabled {
+		if _, ok := factories[name]; !ok {
+			return nil, fmt.Errorf("unknown brokerpak %q, registered brokerpaks are %v", name, slices.Sorted(maps.Keys(factories)))
+		}
+		if slices.Contains(enabled[:i], name) {
+			return nil, fmt.Errorf("brokerpak %q is enabled more than once", name)
+		}
+	}
+
+	var mounted []Brokerpak
+	for _, name := range enabled {
+		bp, err := factories[name](deps)
+		if err != nil {
+			return nil, fmt.Errorf("building brokerpak %v: %w", name, err)
+		}
+		prefix := "/brokerpaks/" + name
+		if err := handle(mux, prefix+"/", http.StripPrefix(prefix, bp.Routes())); err != nil {
+			return nil, fmt.Errorf("mounting brokerpak %v: %w", name, err)
+		}
+		mounted = append(mounted, bp)
+	}
+	return mounted, nil
+}
+
+// handle registers h for pattern on mux, returning the panic of
+// [http.ServeMux.Handle], like for a pattern that is already registered, as an
+// error.
+func handle(mux *http.ServeMux, pattern string, h http.Handler) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("%v", v)
+		}
+	}()
+	mux.Handle(pattern, h)
+	return nil
+}
@@ -0,0 +1,120 @@
+package brokerpaks_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/cloud-gov/csb/helper/internal/brokerpaks"
+	"github.com/cloud-gov/csb/helper/internal/config"
+)
+
+type fakeBrokerpak struct {
+	name string
+}
+
+func (f *fakeBrokerpak) Name() string { return f.name }
+
+func (f *fakeBrokerpak) Routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /hello", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello from "+f.name)
+	})
+	return mux
+}
+
+func (f *fakeBrokerpak) Health(context.Context) error { return nil }
+
+func init() {
+	for _, name := range []string{"fake-a", "fake-b"} {
+		brokerpaks.Register(name, func(brokerpaks.Deps) (brokerpaks.Brokerpak, error) {
+			return &fakeBrokerpak{name: name}, nil
+		})
+	}
+}
+
+func TestRegister(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected Register to panic on a duplicate name")
+		}
+	}()
+	brokerpaks.Register("fake-a", nil)
+}
+
+func TestRegisterConfig(t *testing.T) {
+	vals := config.Values{
+		"HOST":                               {Value: "helper.example.com"},
+		"PORT":                               {Value: "8080"},
+		"BROKER_URL":                         {Value: "https://broker.example.com"},
+		"CG_PLATFORM_NOTIFICATION_TOPIC_ARN": {Value: "arn:aws:sns:us-east-1:123456789012:topic"},
+		"BROKERPAKS":                         {Value: "fake-a,fake-b"},
+	}
+	if _, err := config.Parse(vals); err != nil {
+		t.Fatalf("expected registered brokerpaks to be valid in BROKERPAKS, got %v", err)
+	}
+}
+
+func TestMount(t *testing.T) {
+	t.Run("mounts every registered brokerpak by default", func(t *testing.T) {
+		mux := http.NewServeMux()
+		bps, err := brokerpaks.Mount(mux, brokerpaks.Deps{}, nil)
+		if err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+		var names []string
+		for _, bp := range bps {
+			names = append(names, bp.Name())
+		}
+		if !slices.Equal(names, brokerpaks.Names()) {
+			t.Fatalf("expected %v mounted, got %v", brokerpaks.Names(), names)
+		}
+
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/brokerpaks/fake-b/hello", nil))
+		if got := rec.Body.String(); got != "hello from fake-b" {
+			t.Fatalf("expected routes mounted with prefix stripped, got status %v body %q", rec.Code, got)
+		}
+	})
+
+	t.Run("mounts only enabled brokerpaks", func(t *testing.T) {
+		mux := http.NewServeMux()
+		if _, err := brokerpaks.Mount(mux, brokerpaks.Deps{}, []string{"fake-a"}); err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/brokerpaks/fake-b/hello", nil))
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("expected disabled brokerpak to 404, got %v", rec.Code)
+		}
+	})
+
+	t.Run("repeated brokerpak is an error", func(t *testing.T) {
+		mux := http.NewServeMux()
+		if _, err := brokerpaks.Mount(mux, brokerpaks.Deps{}, []string{"fake-a", "fake-b", "fake-a"}); err == nil {
+			t.Fatal("expected non-nil error")
+		}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/brokerpaks/fake-a/hello", nil))
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("expected nothing mounted, got %v", rec.Code)
+		}
+	})
+
+	t.Run("path already taken is an error", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.Handle("/brokerpaks/fake-a/", http.NotFoundHandler())
+		if _, err := brokerpaks.Mount(mux, brokerpaks.Deps{}, []string{"fake-a"}); err == nil {
+			t.Fatal("expected non-nil error")
+		}
+	})
+
+	t.Run("unknown brokerpak is an error", func(t *testing.T) {
+		if _, err := brokerpaks.Mount(http.NewServeMux(), brokerpaks.Deps{}, []string{"nope"}); err == nil {
+			t.Fatal("expected non-nil error")
+		}
+	})
+}
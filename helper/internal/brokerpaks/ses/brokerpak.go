@@ -0,0 +1,63 @@
+package ses
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsses "github.com/aws/aws-sdk-go-v2/service/ses"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+
+	"github.com/cloud-gov/csb/helper/internal/brokerpaks"
+)
+
+func init() {
+	brokerpaks.Register("ses", New)
+}
+
+// Brokerpak is the helper functionality for the aws-ses brokerpak: it pauses
+// sending on SES identities whose reputation alarms fire.
+type Brokerpak struct {
+	deps      brokerpaks.Deps
+	sesclient SESClient
+	snsclient SNSClient
+}
+
+// New builds the SES brokerpak with clients created from the shared AWS config
+// and any configured endpoint overrides.
+func New(deps brokerpaks.Deps) (brokerpaks.Brokerpak, error) {
+	endpoints := deps.Config.AWSEndpoints
+	return &Brokerpak{
+		deps: deps,
+		sesclient: awsses.NewFromConfig(deps.AWS, func(o *awsses.Options) {
+			if endpoints.SES != "" {
+				o.BaseEndpoint = aws.String(endpoints.SES)
+			}
+		}),
+		snsclient: sns.NewFromConfig(deps.AWS, func(o *sns.Options) {
+			if endpoints.SNS != "" {
+				o.BaseEndpoint = aws.String(endpoints.SNS)
+			}
+		}),
+	}, nil
+}
+
+func (b *Brokerpak) Name() string {
+	return "ses"
+}
+
+func (b *Brokerpak) Routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("POST /reputation-alarm", HandleSNSRequest(b.deps.Logger, b.sesclient, b.snsclient, b.deps.Config.PlatformNotificationsTopicARN, b.deps.SNSDomain))
+	return mux
+}
+
+// Health fails if the SNS signing certificate domain is unknown, because then
+// no SNS message can be verified.
+func (b *Brokerpak) Health(ctx context.Context) error {
+	if b.deps.SNSDomain == "" {
+		return errors.New("SNS signing certificate domain is unknown")
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package ses
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ses"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/cloud-gov/csb/helper/internal/metrics"
+	"github.com/cloud-gov/csb/helper/internal/telemetry"
+)
+
+// Enforcer pauses and resumes sending on SES configuration sets. It is the only
+// code path that changes sending status, whether triggered by an alarm or by an
+// operator, and logs an audit record for every attempt.
+type Enforcer struct {
+	Client SESClient
+	Logger *slog.Logger
+}
+
+// Pause disables sending on the configuration set cset. actor identifies who or
+// what requested the change, like "sns:<message ID>" or "cli:<username>", and
+// reason says why.
+func (e *Enforcer) Pause(ctx context.Context, cset string, actor string, reason string) error {
+	return e.setSendingEnabled(ctx, cset, false, actor, reason)
+}
+
+// Resume re-enables sending on the configuration set cset. See [Enforcer.Pause]
+// for actor and reason.
+func (e *Enforcer) Resume(ctx context.Context, cset string, actor string, reason string) error {
+	return e.setSendingEnabled(ctx, cset, true, actor, reason)
+}
+
+func (e *Enforcer) setSendingEnabled(ctx context.Context, cset string, enabled bool, actor string, reason string) (err error) {
+	action := "pause"
+	if enabled {
+		action = "resume"
+	}
+	ctx, span := tracer.Start(ctx, "SES UpdateConfigurationSetSendingEnabled", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("ses.configuration_set", cset),
+		attribute.Bool("ses.sending_enabled", enabled),
+	))
+	defer span.End()
+	defer func() { telemetry.RecordError(span, err) }()
+
+	_, err = e.Client.UpdateConfigurationSetSendingEnabled(ctx, &ses.UpdateConfigurationSetSendingEnabledInput{
+		ConfigurationSetName: aws.String(cset),
+		Enabled:              enabled,
+	})
+
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+		metrics.SESAPIErrors.WithLabelValues("UpdateConfigurationSetSendingEnabled").Inc()
+	}
+	e.Logger.InfoContext(ctx, "audit: SES configuration set sending status change",
+		"action", action,
+		"configuration-set", cset,
+		"actor", actor,
+		"reason", reason,
+		"outcome", outcome,
+	)
+	if err != nil {
+		return fmt.Errorf("error trying to %v sending on configuration set %v: %w", action, cset, err)
+	}
+	return nil
+}
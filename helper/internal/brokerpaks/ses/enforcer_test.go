@@ -0,0 +1,95 @@
+package ses_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	awsses "github.com/aws/aws-sdk-go-v2/service/ses"
+
+	"github.com/cloud-gov/csb/helper/internal/brokerpaks/ses"
+)
+
+// recordingSESClient records the input of each call so tests can check what was
+// sent to SES.
+type recordingSESClient struct {
+	inputs []*awsses.UpdateConfigurationSetSendingEnabledInput
+	err    error
+}
+
+func (c *recordingSESClient) UpdateConfigurationSetSendingEnabled(ctx context.Context, input *awsses.UpdateConfigurationSetSendingEnabledInput, opts ...func(*awsses.Options)) (*awsses.UpdateConfigurationSetSendingEnabledOutput, error) {
+	c.inputs = append(c.inputs, input)
+	return &awsses.UpdateConfigurationSetSendingEnabledOutput{}, c.err
+}
+
+func TestEnforcerAudits(t *testing.T) {
+	var logs bytes.Buffer
+	client := &recordingSESClient{}
+	e := ses.Enforcer{Client: client, Logger: slog.New(slog.NewTextHandler(&logs, nil))}
+
+	if err := e.Pause(context.Background(), "cset-1", "cli:alice", "testing"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Resume(context.Background(), "cset-1", "cli:alice", "done testing"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(client.inputs) != 2 {
+		t.Fatalf("expected 2 calls to SES, got %v", len(client.inputs))
+	}
+	if in := client.inputs[0]; *in.ConfigurationSetName != "cset-1" || in.Enabled {
+		t.Errorf("pause sent ConfigurationSetName=%v Enabled=%v", *in.ConfigurationSetName, in.Enabled)
+	}
+	if in := client.inputs[1]; !in.Enabled {
+		t.Error("resume sent Enabled=false")
+	}
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 audit lines, got %v:\n%v", len(lines), logs.String())
+	}
+	for _, want := range []string{"action=pause", "configuration-set=cset-1", "actor=cli:alice", "reason=testing", "outcome=success"} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("expected audit line to contain %q, got: %v", want, lines[0])
+		}
+	}
+	if !strings.Contains(lines[1], "action=resume") {
+		t.Errorf("expected second audit line to be a resume, got: %v", lines[1])
+	}
+}
+
+func TestEnforcerAuditsFailures(t *testing.T) {
+	var logs bytes.Buffer
+	sesErr := errors.New("throttled")
+	e := ses.Enforcer{Client: &recordingSESClient{err: sesErr}, Logger: slog.New(slog.NewTextHandler(&logs, nil))}
+
+	err := e.Pause(context.Background(), "cset-1", "sns:1234", "alarm")
+	if !errors.Is(err, sesErr) {
+		t.Errorf("expected error wrapping %v, got %v", sesErr, err)
+	}
+	if !strings.Contains(logs.String(), "outcome=failure") {
+		t.Errorf("expected a failed audit line, got: %v", logs.String())
+	}
+}
+
+func TestExplainVerificationError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"wrapped sentinel", errors.Join(errors.New("context"), ses.ErrSNSWrongTopicARN), "different topic"},
+		{"signature", ses.ErrSNSSignatureVerification, "does not match"},
+		{"other", errors.New("connection refused"), "could not be fetched"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ses.ExplainVerificationError(tt.err); !strings.Contains(got, tt.want) {
+				t.Errorf("expected explanation containing %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
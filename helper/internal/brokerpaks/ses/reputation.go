@@ -9,15 +9,24 @@ import (
 	"net/http"
 	"strings"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ses"
 	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/cloud-gov/csb/helper/internal/metrics"
+	"github.com/cloud-gov/csb/helper/internal/telemetry"
 )
 
+var tracer = otel.Tracer("github.com/cloud-gov/csb/helper/internal/brokerpaks/ses")
+
 const (
 	snsMessageTypeHeader                   = "x-amz-sns-message-type"
 	snsMessageTypeNotification             = "Notification"
 	snsMessageTypeSubscriptionConfirmation = "SubscriptionConfirmation"
+	snsMessageTypeUnsubscribeConfirmation  = "UnsubscribeConfirmation"
 )
 
 type SESClient interface {
@@ -59,6 +68,14 @@ func (a *CloudWatchAlarm) Valid() map[string]string {
 	return verrs
 }
 
+// Class returns the alarm name without its identity suffix, for example
+// "SES-BounceRate-Critical". The class is bounded, unlike the full alarm name,
+// so it is suitable as a metrics label.
+func (a *CloudWatchAlarm) Class() string {
+	class, _, _ := strings.Cut(a.AlarmName, "-Identity-")
+	return class
+}
+
 func UnmarshalMessage(body io.Reader) (SNSMessage, error) {
 	var s SNSMessage
 	b, err := io.ReadAll(body)
@@ -75,22 +92,32 @@ func UnmarshalMessage(body io.Reader) (SNSMessage, error) {
 	return s, nil
 }
 
-func handleSubscriptionConfirmation(ctx context.Context, logger *slog.Logger, msg SNSMessage, client SNSClient) error {
-	_, err := client.ConfirmSubscription(ctx, &sns.ConfirmSubscriptionInput{
+func handleSubscriptionConfirmation(ctx context.Context, logger *slog.Logger, msg SNSMessage, client SNSClient) (err error) {
+	ctx, span := tracer.Start(ctx, "handleSubscriptionConfirmation", trace.WithAttributes(
+		attribute.String("sns.topic_arn", msg.TopicArn),
+	))
+	defer span.End()
+	defer func() { telemetry.RecordError(span, err) }()
+
+	_, err = client.ConfirmSubscription(ctx, &sns.ConfirmSubscriptionInput{
 		Token:    &msg.Token,
 		TopicArn: &msg.TopicArn,
 	})
 	if err != nil {
 		return err
 	} else {
-		logger.Info("confirmed subscription to SNS topic", "topic", msg.TopicArn)
+		logger.InfoContext(ctx, "confirmed subscription to SNS topic", "topic", msg.TopicArn)
 		return nil
 	}
 }
 
-func handleNotification(ctx context.Context, logger *slog.Logger, msg SNSMessage, sesclient SESClient) error {
+func handleNotification(ctx context.Context, logger *slog.Logger, msg SNSMessage, sesclient SESClient) (err error) {
+	ctx, span := tracer.Start(ctx, "handleNotification")
+	defer span.End()
+	defer func() { telemetry.RecordError(span, err) }()
+
 	var a CloudWatchAlarm
-	err := json.Unmarshal([]byte(msg.Message), &a)
+	err = json.Unmarshal([]byte(msg.Message), &a)
 	if err != nil {
 		return fmt.Errorf("unmarshalling CloudWatch alarm from SNS message body: %w", err)
 	}
@@ -100,14 +127,16 @@ func handleNotification(ctx context.Context, logger *slog.Logger, msg SNSMessage
 	}
 
 	cset := a.Trigger.Dimensions[0].Value
-	logger.Info("pausing sending on SES identity via Configuration Set", "configuration-set", cset)
-	_, err = sesclient.UpdateConfigurationSetSendingEnabled(ctx, &ses.UpdateConfigurationSetSendingEnabledInput{
-		ConfigurationSetName: aws.String(cset),
-		Enabled:              false,
-	})
-	if err != nil {
-		return fmt.Errorf("error pausing sending on configuration set %v: %w", cset, err)
+	span.SetAttributes(
+		attribute.String("cloudwatch.alarm_class", a.Class()),
+		attribute.String("ses.configuration_set", cset),
+	)
+	logger.InfoContext(ctx, "pausing sending on SES identity via Configuration Set", "configuration-set", cset)
+	enforcer := Enforcer{Client: sesclient, Logger: logger}
+	if err = enforcer.Pause(ctx, cset, "sns:"+msg.MessageId, "CloudWatch alarm "+a.AlarmName); err != nil {
+		return err
 	}
+	metrics.SESPauses.WithLabelValues(a.Class()).Inc()
 	return nil
 }
 
@@ -115,37 +144,53 @@ func handleNotification(ctx context.Context, logger *slog.Logger, msg SNSMessage
 func HandleSNSRequest(logger *slog.Logger, sesclient SESClient, snsclient SNSClient, topicarn string, snsdomain string) http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := telemetry.StartServerSpan(r, tracer, "POST /brokerpaks/ses/reputation-alarm")
+			defer span.End()
+
 			defer r.Body.Close() // todo, can return an error
 			msg, err := UnmarshalMessage(r.Body)
 			if err != nil {
-				logger.Error("error processing CloudWatch alarm SNS request", "err", err)
+				logger.ErrorContext(ctx, "error processing CloudWatch alarm SNS request", "err", err)
 				w.WriteHeader(http.StatusBadRequest)
 				return
 			}
 
-			if err = VerifySNSMessage(msg, snsdomain, topicarn); err != nil {
-				logger.Error("failed to verify SNS message signature", "err", err)
+			if err = VerifySNSMessage(ctx, msg, snsdomain, topicarn); err != nil {
+				logger.ErrorContext(ctx, "failed to verify SNS message signature", "err", err)
+				span.SetStatus(codes.Error, "SNS message verification failed")
+				metrics.SNSVerificationFailures.WithLabelValues(verificationFailureReason(err)).Inc()
 				w.WriteHeader(http.StatusBadRequest)
 				return
 			}
 
 			// once verified, switch on request type
 			mtype := r.Header.Get(snsMessageTypeHeader)
+			span.SetAttributes(attribute.String("sns.message_type", mtype))
 			if mtype == "" {
-				logger.Error("SNS message passed verification but type header was empty -- this should never happen")
+				logger.ErrorContext(ctx, "SNS message passed verification but type header was empty -- this should never happen")
 				w.WriteHeader(http.StatusBadRequest)
 				return
 			}
 			switch mtype {
+			case snsMessageTypeSubscriptionConfirmation, snsMessageTypeNotification, snsMessageTypeUnsubscribeConfirmation:
+				metrics.SNSMessages.WithLabelValues(mtype).Inc()
+			default:
+				// The header is caller-controlled, so don't let it create new
+				// label values.
+				metrics.SNSMessages.WithLabelValues("unknown").Inc()
+			}
+			switch mtype {
 			case snsMessageTypeSubscriptionConfirmation:
-				if err = handleSubscriptionConfirmation(r.Context(), logger, msg, snsclient); err != nil {
-					logger.Error("error confirming SNS subscription", "err", err)
+				if err = handleSubscriptionConfirmation(ctx, logger, msg, snsclient); err != nil {
+					logger.ErrorContext(ctx, "error confirming SNS subscription", "err", err)
+					span.SetStatus(codes.Error, "confirming SNS subscription failed")
 					w.WriteHeader(http.StatusInternalServerError)
 					return
 				}
 			case snsMessageTypeNotification:
-				if err = handleNotification(r.Context(), logger, msg, sesclient); err != nil {
-					logger.Error("error handling SNS notification", "err", err)
+				if err = handleNotification(ctx, logger, msg, sesclient); err != nil {
+					logger.ErrorContext(ctx, "error handling SNS notification", "err", err)
+					span.SetStatus(codes.Error, "handling SNS notification failed")
 					w.WriteHeader(http.StatusInternalServerError)
 					return
 				}
@@ -8,15 +8,35 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"slices"
 	"strings"
 	"testing"
 
 	awsses "github.com/aws/aws-sdk-go-v2/service/ses"
 	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/cloud-gov/csb/helper/internal/brokerpaks/ses"
+	"github.com/cloud-gov/csb/helper/internal/metrics"
 )
 
+// spans records every span ended during the tests. The global tracer provider
+// can only be delegated to once, so it is installed for the whole package in
+// TestMain.
+var spans = tracetest.NewInMemoryExporter()
+
+func TestMain(m *testing.M) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans)))
+	os.Exit(m.Run())
+}
+
 var referenceAlarm = `{
     "Type": "Notification",
     "MessageId": "xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx",
@@ -267,4 +287,139 @@ func TestHandleSNSRequest(t *testing.T) {
 			t.Fatalf("expected HTTP status %v, got %v", http.StatusOK, code)
 		}
 	})
+	t.Run("wrong topic ARN counts a verification failure", func(t *testing.T) {
+		arn := "arn:aws:sns:us-east-1:123456789012:MyTopic"
+		msg, ts := newSignedMessage(t, arn)
+		defer ts.Close()
+
+		u, err := url.Parse(ts.URL)
+		if err != nil {
+			t.Fatal("problem with the test; httptest server URL not valid")
+		}
+
+		body, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatal("error marshalling test request JSON. This is a problem with the test.", err)
+		}
+		req, err := http.NewRequest("POST", "localhost/brokerpaks/ses/reputation-alarm", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal("error creating the test HTTP request. This is a problem with the test.", err)
+		}
+		req.Header.Add("x-amz-sns-message-type", "Notification")
+
+		counter := metrics.SNSVerificationFailures.WithLabelValues("wrong_topic_arn")
+		before := testutil.ToFloat64(counter)
+
+		rec := httptest.NewRecorder()
+		ses.HandleSNSRequest(slog.Default(), &MockSESClient{}, &MockSNSClient{}, "arn:aws:sns:us-east-1:123456789012:OtherTopic", u.Host).ServeHTTP(rec, req)
+		if code := rec.Result().StatusCode; code != http.StatusBadRequest {
+			t.Fatalf("expected HTTP status %v, got %v", http.StatusBadRequest, code)
+		}
+		if got := testutil.ToFloat64(counter) - before; got != 1 {
+			t.Fatalf("expected verification failure counter to increase by 1, got %v", got)
+		}
+	})
+	t.Run("notification is traced from handler to SES call", func(t *testing.T) {
+		arn := "arn:aws:sns:us-east-1:123456789012:MyTopic"
+		msg, ts := newSignedMessage(t, arn)
+		defer ts.Close()
+
+		u, err := url.Parse(ts.URL)
+		if err != nil {
+			t.Fatal("problem with the test; httptest server URL not valid")
+		}
+
+		body, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatal("error marshalling test request JSON. This is a problem with the test.", err)
+		}
+		req, err := http.NewRequest("POST", "localhost/brokerpaks/ses/reputation-alarm", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal("error creating the test HTTP request. This is a problem with the test.", err)
+		}
+		req.Header.Add("x-amz-sns-message-type", "Notification")
+
+		spans.Reset()
+		rec := httptest.NewRecorder()
+		ses.HandleSNSRequest(slog.Default(), &MockSESClient{}, &MockSNSClient{}, arn, u.Host).ServeHTTP(rec, req)
+
+		got := spans.GetSpans()
+		var names []string
+		for _, s := range got {
+			names = append(names, s.Name)
+		}
+		for _, want := range []string{"POST /brokerpaks/ses/reputation-alarm", "VerifySNSMessage", "fetchSigningCert", "CheckSignature", "handleNotification"} {
+			if !slices.Contains(names, want) {
+				t.Errorf("expected span %q, got spans %v", want, names)
+			}
+		}
+
+		// The test message body is not a CloudWatch alarm, so
+		// handleNotification fails before calling SES.
+		i := slices.IndexFunc(got, func(s tracetest.SpanStub) bool { return s.Name == "handleNotification" })
+		if i < 0 {
+			t.FailNow()
+		}
+		if got[i].Status.Code != codes.Error {
+			t.Errorf("expected handleNotification span to have error status, got %v", got[i].Status.Code)
+		}
+		root := got[slices.IndexFunc(got, func(s tracetest.SpanStub) bool { return s.Name == "POST /brokerpaks/ses/reputation-alarm" })]
+		if got[i].Parent.SpanID() != root.SpanContext.SpanID() {
+			t.Errorf("expected handleNotification to be a child of the handler span")
+		}
+	})
+	t.Run("alarm is traced to the SES call", func(t *testing.T) {
+		arn := "arn:aws:sns:us-east-1:123456789012:MyTopic"
+		alarm := `{"AlarmName":"SES-BounceRate-Critical-Identity-ExampleSet","NewStateValue":"ALARM","Trigger":{"Dimensions":[{"Name":"ConfigurationSetName","Value":"ExampleSet"}]}}`
+		msg, ts := newSignedNotification(t, arn, alarm)
+		defer ts.Close()
+
+		u, err := url.Parse(ts.URL)
+		if err != nil {
+			t.Fatal("problem with the test; httptest server URL not valid")
+		}
+		body, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatal("error marshalling test request JSON. This is a problem with the test.", err)
+		}
+		req, err := http.NewRequest("POST", "localhost/brokerpaks/ses/reputation-alarm", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal("error creating the test HTTP request. This is a problem with the test.", err)
+		}
+		req.Header.Add("x-amz-sns-message-type", "Notification")
+
+		spans.Reset()
+		rec := httptest.NewRecorder()
+		ses.HandleSNSRequest(slog.Default(), &MockSESClient{ReturnOutput: &awsses.UpdateConfigurationSetSendingEnabledOutput{}}, &MockSNSClient{}, arn, u.Host).ServeHTTP(rec, req)
+		if code := rec.Result().StatusCode; code != http.StatusOK {
+			t.Fatalf("expected HTTP status %v, got %v", http.StatusOK, code)
+		}
+
+		got := spans.GetSpans()
+		i := slices.IndexFunc(got, func(s tracetest.SpanStub) bool { return s.Name == "SES UpdateConfigurationSetSendingEnabled" })
+		if i < 0 {
+			t.Fatalf("expected an SES call span, got %v", got)
+		}
+		call := got[i]
+		if call.SpanKind != trace.SpanKindClient {
+			t.Errorf("expected SES call span to be a client span, got %v", call.SpanKind)
+		}
+		if call.Status.Code == codes.Error {
+			t.Errorf("expected SES call span not to have error status")
+		}
+		attrs := map[attribute.Key]attribute.Value{}
+		for _, a := range call.Attributes {
+			attrs[a.Key] = a.Value
+		}
+		if v := attrs["ses.configuration_set"]; v.AsString() != "ExampleSet" {
+			t.Errorf("expected ses.configuration_set ExampleSet, got %v", v.Emit())
+		}
+		if v, ok := attrs["ses.sending_enabled"]; !ok || v.AsBool() {
+			t.Errorf("expected ses.sending_enabled false, got %v", v.Emit())
+		}
+		parent := got[slices.IndexFunc(got, func(s tracetest.SpanStub) bool { return s.Name == "handleNotification" })]
+		if call.Parent.SpanID() != parent.SpanContext.SpanID() {
+			t.Errorf("expected the SES call to be a child of handleNotification")
+		}
+	})
 }
@@ -1,6 +1,7 @@
 package ses
 
 import (
+	"context"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/pem"
@@ -10,6 +11,11 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/cloud-gov/csb/helper/internal/telemetry"
 )
 
 var (
@@ -23,6 +29,46 @@ var (
 	ErrSNSWrongTopicARN               = errors.New("sns: unexpected topic ARN")
 )
 
+// snsErrReasons maps each ErrSNS* sentinel to a short label for metrics and an
+// explanation for operators.
+var snsErrReasons = []struct {
+	err         error
+	reason      string
+	explanation string
+}{
+	{ErrSNSUnsupportedSignatureVersion, "unsupported_signature_version", "The message's SignatureVersion is not 1. Only SHA1 signatures (version 1) are supported."},
+	{ErrSNSMissingSigningCertURL, "missing_signing_cert_url", "The message has no SigningCertURL, so its signature can't be checked. Was the payload captured in full?"},
+	{ErrSNSMalformedSigningCertURL, "malformed_signing_cert_url", "The message's SigningCertURL is not a valid URL."},
+	{ErrSNSWrongSigningCertDomain, "wrong_signing_cert_domain", "The SigningCertURL is not on the expected SNS domain. The message may be forged, or the helper may be resolving the wrong region."},
+	{ErrSNSPEMDecode, "pem_decode", "The certificate at SigningCertURL is not PEM-encoded."},
+	{ErrSNSPublicKeyRSA, "public_key_rsa", "The certificate at SigningCertURL does not have an RSA public key."},
+	{ErrSNSSignatureVerification, "signature_verification", "The signature does not match the message. The message may have been modified, for example by reformatting the JSON, or forged."},
+	{ErrSNSWrongTopicARN, "wrong_topic_arn", "The message is authentic but was published to a different topic than the helper is configured for."},
+}
+
+// ExplainVerificationError describes, for an operator, why [VerifySNSMessage]
+// returned err.
+func ExplainVerificationError(err error) string {
+	for _, r := range snsErrReasons {
+		if errors.Is(err, r.err) {
+			return r.explanation
+		}
+	}
+	return "The signing certificate could not be fetched or parsed, or the signature could not be decoded. See the error for details."
+}
+
+// verificationFailureReason returns the metrics label for the ErrSNS* sentinel
+// wrapped by err, or "other" if err does not wrap one, such as when the
+// certificate could not be fetched.
+func verificationFailureReason(err error) string {
+	for _, r := range snsErrReasons {
+		if errors.Is(err, r.err) {
+			return r.reason
+		}
+	}
+	return "other"
+}
+
 // SNSMessage represents the fields from an SNS JSON message.
 // Message formats are described here: https://docs.aws.amazon.com/sns/latest/dg/sns-message-and-json-formats.html
 type SNSMessage struct {
@@ -45,7 +91,14 @@ type SNSMessage struct {
 // In addition to the steps described by AWS, the function also checks that the topic ARN in the message matches the arn that the application expects.
 //
 // [AWS documentation]: https://docs.aws.amazon.com/sns/latest/dg/sns-verify-signature-of-message-verify-message-signature.html
-func VerifySNSMessage(msg SNSMessage, snsdomain string, arn string) error {
+func VerifySNSMessage(ctx context.Context, msg SNSMessage, snsdomain string, arn string) (err error) {
+	ctx, span := tracer.Start(ctx, "VerifySNSMessage", trace.WithAttributes(
+		attribute.String("sns.message_id", msg.MessageId),
+		attribute.String("sns.topic_arn", msg.TopicArn),
+	))
+	defer span.End()
+	defer func() { telemetry.RecordError(span, err) }()
+
 	if msg.SignatureVersion != "1" {
 		return ErrSNSUnsupportedSignatureVersion
 	}
@@ -63,14 +116,7 @@ func VerifySNSMessage(msg SNSMessage, snsdomain string, arn string) error {
 		return fmt.Errorf("wanted %v, got %v: %w", snsdomain, u.Host, ErrSNSWrongSigningCertDomain)
 	}
 
-	// Fetch the signing certificate
-	certResp, err := http.Get(msg.SigningCertURL)
-	if err != nil {
-		return err
-	}
-	defer certResp.Body.Close()
-
-	certBytes, err := io.ReadAll(certResp.Body)
+	certBytes, err := fetchSigningCert(ctx, msg.SigningCertURL)
 	if err != nil {
 		return err
 	}
@@ -94,7 +140,9 @@ func VerifySNSMessage(msg SNSMessage, snsdomain string, arn string) error {
 	toSign := buildStringToSign(msg)
 
 	// Check the decoded signature
+	_, sigSpan := tracer.Start(ctx, "CheckSignature")
 	err = cert.CheckSignature(x509.SHA1WithRSA, []byte(toSign), signature)
+	sigSpan.End()
 	if err != nil {
 		return fmt.Errorf("%w: %w", ErrSNSSignatureVerification, err)
 	}
@@ -107,6 +155,29 @@ func VerifySNSMessage(msg SNSMessage, snsdomain string, arn string) error {
 	return nil
 }
 
+// fetchSigningCert downloads the PEM-encoded certificate at certURL. The caller
+// is responsible for checking that certURL is on a trusted domain.
+func fetchSigningCert(ctx context.Context, certURL string) (b []byte, err error) {
+	ctx, span := tracer.Start(ctx, "fetchSigningCert", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("url.full", certURL),
+	))
+	defer span.End()
+	defer func() { telemetry.RecordError(span, err) }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, certURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	certResp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer certResp.Body.Close()
+	span.SetAttributes(attribute.Int("http.response.status_code", certResp.StatusCode))
+
+	return io.ReadAll(certResp.Body)
+}
+
 // buildStringToSign constructs the correct string to sign based on the message type.
 // AWS docs: https://docs.aws.amazon.com/sns/latest/dg/sns-verify-signature-of-message-verify-message-signature.html
 // Note that the above docs are NOT entirely correct: In practice, you must add a trailing newline, or verification will fail. Also, the parent page is not correct: As of this commit, there are no functions to help with signature validation in the SDK.
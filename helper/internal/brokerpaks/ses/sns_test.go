@@ -1,6 +1,7 @@
 package ses_test
 
 import (
+	"context"
 	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
@@ -14,6 +15,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -38,22 +40,27 @@ func errIs(t *testing.T, actual, expected error) {
 	}
 }
 
-var stringToSign = `Message
-Hello
-MessageId
-mid
-Subject
-subject
-Timestamp
-2021-01-01T00:00:00Z
-TopicArn
-arn:aws:sns:us-east-1:123456789012:MyTopic
-Type
-Notification
-`
+// stringToSign is what SNS signs for a notification: its fields, each name
+// followed by its value on the next line.
+func stringToSign(msg ses.SNSMessage) string {
+	return strings.Join([]string{
+		"Message", msg.Message,
+		"MessageId", msg.MessageId,
+		"Subject", msg.Subject,
+		"Timestamp", msg.Timestamp,
+		"TopicArn", msg.TopicArn,
+		"Type", msg.Type,
+	}, "\n") + "\n"
+}
 
 // newSignedMessage creates a new signed SNSMessage and an httptest.Server that serves the signing certificate. The TopicARN will be populated with arn. The caller is responsible for calling Close on the test server.
 func newSignedMessage(t *testing.T, arn string) (ses.SNSMessage, *httptest.Server) {
+	return newSignedNotification(t, arn, "Hello")
+}
+
+// newSignedNotification is [newSignedMessage] with message as the notification
+// body.
+func newSignedNotification(t *testing.T, arn string, message string) (ses.SNSMessage, *httptest.Server) {
 	// 1. Generate an RSA key pair
 	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
 	errNil(t, err)
@@ -81,7 +88,7 @@ func newSignedMessage(t *testing.T, arn string) (ses.SNSMessage, *httptest.Serve
 	testMsg := ses.SNSMessage{
 		Type:             "Notification",
 		MessageId:        "mid",
-		Message:          "Hello",
+		Message:          message,
 		Subject:          "subject",
 		Timestamp:        "2021-01-01T00:00:00Z",
 		TopicArn:         arn,
@@ -90,7 +97,7 @@ func newSignedMessage(t *testing.T, arn string) (ses.SNSMessage, *httptest.Serve
 	}
 
 	// 6. Hash and sign the known good string-to-sign
-	hashed := sha1.Sum([]byte(stringToSign))
+	hashed := sha1.Sum([]byte(stringToSign(testMsg)))
 	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA1, hashed[:])
 	errNil(t, err)
 
@@ -106,7 +113,7 @@ func TestVerifySNSMessage(t *testing.T) {
 		msg := ses.SNSMessage{
 			SignatureVersion: "2",
 		}
-		err := ses.VerifySNSMessage(msg, "sns.example.com", "")
+		err := ses.VerifySNSMessage(context.Background(), msg, "sns.example.com", "")
 		errIs(t, err, ses.ErrSNSUnsupportedSignatureVersion)
 	})
 
@@ -114,7 +121,7 @@ func TestVerifySNSMessage(t *testing.T) {
 		msg := ses.SNSMessage{
 			SignatureVersion: "1",
 		}
-		err := ses.VerifySNSMessage(msg, "sns.example.com", "")
+		err := ses.VerifySNSMessage(context.Background(), msg, "sns.example.com", "")
 		errIs(t, err, ses.ErrSNSMissingSigningCertURL)
 	})
 
@@ -123,7 +130,7 @@ func TestVerifySNSMessage(t *testing.T) {
 			SignatureVersion: "1",
 			SigningCertURL:   "https://malicious.com/cert.pem",
 		}
-		err := ses.VerifySNSMessage(msg, "sns.example.com", "")
+		err := ses.VerifySNSMessage(context.Background(), msg, "sns.example.com", "")
 		errIs(t, err, ses.ErrSNSWrongSigningCertDomain)
 	})
 
@@ -133,7 +140,7 @@ func TestVerifySNSMessage(t *testing.T) {
 			SignatureVersion: "1",
 			SigningCertURL:   "http://127.0.0.1:9999/cert.pem",
 		}
-		err := ses.VerifySNSMessage(msg, "127.0.0.1:9999", "")
+		err := ses.VerifySNSMessage(context.Background(), msg, "127.0.0.1:9999", "")
 		errNotNil(t, err)
 	})
 
@@ -149,7 +156,7 @@ func TestVerifySNSMessage(t *testing.T) {
 		}
 		hostPort := u.Host
 
-		err = ses.VerifySNSMessage(msg, hostPort, "bad arn")
+		err = ses.VerifySNSMessage(context.Background(), msg, hostPort, "bad arn")
 		errIs(t, err, ses.ErrSNSWrongTopicARN)
 	})
 
@@ -167,7 +174,7 @@ func TestVerifySNSMessage(t *testing.T) {
 		hostPort := u.Host
 
 		// Confirm the message verifies without error
-		err = ses.VerifySNSMessage(msg, hostPort, arn)
+		err = ses.VerifySNSMessage(context.Background(), msg, hostPort, arn)
 		errNil(t, err)
 	})
 }
@@ -227,6 +234,6 @@ func FuzzVerifySNSMessage(f *testing.F) {
 		}
 
 		// Drop the error, since we're only fuzzing for panics.
-		_ = ses.VerifySNSMessage(msg, "example.com", "")
+		_ = ses.VerifySNSMessage(context.Background(), msg, "example.com", "")
 	})
 }
@@ -1,10 +1,19 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"maps"
 	"net/url"
-	"os"
+	"path"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloud-gov/csb/helper/internal/ratelimit"
 )
 
 type Config struct {
@@ -14,46 +23,492 @@ type Config struct {
 	ListenAddr string
 	// Port is the TCP port the process will listen on. Specified separately because Cloud Foundry provides it to applications automatically.
 	Port uint16
+	// InternalPort is the TCP port for operator endpoints, like /metrics, that
+	// must not be publicly routed. Route it with a Cloud Foundry internal route
+	// only. If zero, the internal listener is disabled.
+	InternalPort uint16
+	// PublicBasePath is the path the helper's pages are published under, like
+	// "/docs/" when a proxy routes that path to the helper. It starts and ends
+	// with "/". Links, assets and redirects in the docs are generated under it.
+	// Defaults to "/".
+	PublicBasePath string
+	// NavLinks are the links in the cloud.gov header of the docs and the
+	// helper's other pages.
+	NavLinks []Link
 	// BrokerURL is the URL of the Cloud Service Broker instance that serves the documentation page.
 	BrokerURL url.URL
+	// BrokerUsername and BrokerPassword are the broker's basic auth
+	// credentials. If set, the docs are rendered from the broker's OSBAPI
+	// catalog, and the docs page is only a fallback for when the catalog can't
+	// be fetched.
+	BrokerUsername string
+	BrokerPassword string
 	// PlatformNotificationsTopicARN is the ARN of an AWS SNS topic which the helper can subscribe to.
 	PlatformNotificationsTopicARN string
+	// AWSEndpoints overrides the endpoints of AWS services, for running against
+	// a local emulator instead of AWS.
+	AWSEndpoints AWSEndpoints
+	// SNSSigningCertDomain is the domain that SNS signing certificates must be
+	// served from. If empty, it is the host of AWSEndpoints.SNS, if set, or
+	// else the regional SNS endpoint.
+	SNSSigningCertDomain string
+	// Brokerpaks names the brokerpaks to mount under /brokerpaks/. If nil,
+	// every registered brokerpak is mounted.
+	Brokerpaks []string
+	// DocsCacheTTL is how long the rendered docs page is served before it is
+	// revalidated with the broker.
+	DocsCacheTTL time.Duration
+	// DocsRulesFile is the path to a JSON file of rules for rewriting the
+	// broker docs page. If empty, the built-in rules are used.
+	DocsRulesFile string
+	// CSPReportOnly sends the Content-Security-Policy of public pages in
+	// report-only mode, so violations are reported rather than blocked.
+	// Defaults to true.
+	CSPReportOnly bool
+	// CSPReportURI is where browsers send reports of Content-Security-Policy
+	// violations. If empty, violations are only logged in the browser console.
+	CSPReportURI string
+	// RateLimits are the per-client limits for each route group: "docs". A zero
+	// Limit means unlimited.
+	RateLimits map[string]ratelimit.Limit
+	// TrustedHops is the number of proxies in front of the helper that append
+	// to X-Forwarded-For. The client IP used for rate limiting is that many
+	// entries from the right.
+	TrustedHops int
+	// UAA configures verification of UAA tokens for the admin APIs. If
+	// UAA.JWKSURL is empty, the admin APIs are not served.
+	UAA UAA
+	// TracesEndpoint is the full URL of an OTLP/HTTP collector, like
+	// "https://collector.example.com:4318/v1/traces". If empty, tracing is
+	// disabled.
+	TracesEndpoint string
+}
+
+// AWSEndpoints are base URLs for AWS service clients. Empty fields use the
+// SDK's default endpoint resolution.
+type AWSEndpoints struct {
+	SES string
+	SNS string
+}
+
+// Link is a navigation link.
+type Link struct {
+	Text string
+	URL  string
+}
+
+// defaultNavLinks are the header links of the rest of cloud.gov.
+const defaultNavLinks = "Docs=https://cloud.gov/docs/, Pricing=https://cloud.gov/pricing/, Status=https://cloudgov.statuspage.io/, Contact=https://cloud.gov/contact/"
+
+// UAA identifies the Cloud Foundry UAA that issues tokens for the helper's
+// APIs.
+type UAA struct {
+	// JWKSURL is the URL of the UAA signing keys, like
+	// "https://uaa.fr.cloud.gov/token_keys".
+	JWKSURL string
+	// Issuer is the expected iss claim, like
+	// "https://uaa.fr.cloud.gov/oauth/token". If empty, it is not checked.
+	Issuer string
+	// Audience is a value the aud claim must contain. If empty, it is not
+	// checked.
+	Audience string
+}
+
+// Key describes a configuration key. Keys have the same name in every source.
+type Key struct {
+	Name string
+	// Secret keys are redacted by [Values.Redacted].
+	Secret bool
+	// Static keys are only read at startup. Changing them requires a restart
+	// rather than a reload.
+	Static bool
+}
+
+// Keys lists every configuration key the helper understands.
+var Keys = []Key{
+	{Name: "HOST"},
+	{Name: "LISTEN_ADDR", Static: true},
+	{Name: "PORT", Static: true},
+	{Name: "INTERNAL_PORT", Static: true},
+	{Name: "PUBLIC_BASE_PATH"},
+	{Name: "NAV_LINKS"},
+	{Name: "BROKER_URL"},
+	{Name: "BROKER_USERNAME"},
+	{Name: "BROKER_PASSWORD", Secret: true},
+	{Name: "CG_PLATFORM_NOTIFICATION_TOPIC_ARN"},
+	{Name: "BROKERPAKS"},
+	{Name: "AWS_ENDPOINT_URL_SES"},
+	{Name: "AWS_ENDPOINT_URL_SNS"},
+	{Name: "SNS_SIGNING_CERT_DOMAIN"},
+	{Name: "DOCS_CACHE_TTL"},
+	{Name: "DOCS_RULES_FILE"},
+	{Name: "CSP_REPORT_ONLY"},
+	{Name: "CSP_REPORT_URI"},
+	{Name: "RATE_LIMIT_DOCS"},
+	{Name: "RATE_LIMIT_TRUSTED_HOPS"},
+	{Name: "UAA_JWKS_URL", Static: true},
+	{Name: "UAA_ISSUER", Static: true},
+	{Name: "UAA_AUDIENCE", Static: true},
+	{Name: "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", Static: true},
+}
+
+var (
+	brokerpaksMu sync.Mutex
+	brokerpaks   = map[string]bool{}
+)
+
+// RegisterBrokerpak makes name a valid value in BROKERPAKS. The brokerpaks
+// package calls it for every brokerpak it registers; config can't ask that
+// package itself, since the package imports config.
+func RegisterBrokerpak(name string) {
+	brokerpaksMu.Lock()
+	defer brokerpaksMu.Unlock()
+	brokerpaks[name] = true
 }
 
+// knownBrokerpak reports whether name was registered with [RegisterBrokerpak].
+func knownBrokerpak(name string) bool {
+	brokerpaksMu.Lock()
+	defer brokerpaksMu.Unlock()
+	return brokerpaks[name]
+}
+
+func lookupKey(name string) (Key, bool) {
+	for _, k := range Keys {
+		if k.Name == name {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+// snsTopicARN matches ARNs of SNS topics, standard or FIFO, in any AWS
+// partition. Topic names, including the ".fifo" suffix of FIFO topics, are at
+// most 256 characters.
+var snsTopicARN = regexp.MustCompile(`^arn:aws(-[a-z]+)*:sns:[a-z0-9-]+:[0-9]{12}:([A-Za-z0-9_-]{1,256}|[A-Za-z0-9_-]{1,251}\.fifo)$`)
+
+// Load reads configuration from every source in order of precedence, as
+// described in [Sources], and validates it.
 func Load() (Config, error) {
+	vals, err := Sources()
+	if err != nil {
+		return Config{}, err
+	}
+	return Parse(vals)
+}
+
+// Parse builds a Config from vals and validates it. Every problem is reported,
+// joined into one error, rather than only the first.
+func Parse(vals Values) (Config, error) {
+	p := parser{vals: vals}
 	c := Config{}
 
-	c.Host = os.Getenv("HOST")
-	c.ListenAddr = os.Getenv("LISTEN_ADDR")
+	for _, name := range slices.Sorted(maps.Keys(vals)) {
+		if _, ok := lookupKey(name); !ok {
+			p.fail(name, "unknown key (from %v)", vals[name].Source)
+		}
+	}
+
+	c.Host = p.required("HOST")
+	if strings.Contains(c.Host, "/") {
+		p.fail("HOST", "must be a bare host name like services.cloud.gov, without a scheme or path, got '%v'", c.Host)
+	}
+	c.ListenAddr = p.get("LISTEN_ADDR")
+	c.Port = p.port("PORT", true)
+	c.InternalPort = p.port("INTERNAL_PORT", false)
+	if c.InternalPort != 0 && c.InternalPort == c.Port {
+		p.fail("INTERNAL_PORT", "must differ from PORT, both were %v", c.Port)
+	}
+
+	c.PublicBasePath = p.basePath("PUBLIC_BASE_PATH")
+	c.NavLinks = p.links("NAV_LINKS", defaultNavLinks)
+
+	if u := p.url("BROKER_URL", true); u != nil {
+		c.BrokerURL = *u
+	}
+	c.BrokerUsername = p.get("BROKER_USERNAME")
+	c.BrokerPassword = p.get("BROKER_PASSWORD")
+	if (c.BrokerUsername == "") != (c.BrokerPassword == "") {
+		p.fail("BROKER_PASSWORD", "BROKER_USERNAME and BROKER_PASSWORD must be set together")
+	}
+
+	c.PlatformNotificationsTopicARN = p.required("CG_PLATFORM_NOTIFICATION_TOPIC_ARN")
+	if arn := c.PlatformNotificationsTopicARN; arn != "" && !snsTopicARN.MatchString(arn) {
+		p.fail("CG_PLATFORM_NOTIFICATION_TOPIC_ARN", "not an SNS topic ARN: '%v'", arn)
+	}
+
+	c.Brokerpaks = p.list("BROKERPAKS")
+	for i, name := range c.Brokerpaks {
+		switch {
+		case !knownBrokerpak(name):
+			p.fail("BROKERPAKS", "unknown brokerpak '%v'", name)
+		case slices.Contains(c.Brokerpaks[:i], name):
+			p.fail("BROKERPAKS", "brokerpak '%v' is listed more than once", name)
+		}
+	}
+
+	c.AWSEndpoints, c.SNSSigningCertDomain = p.aws()
+
+	c.DocsCacheTTL = p.duration("DOCS_CACHE_TTL", 5*time.Minute)
+	c.DocsRulesFile = p.get("DOCS_RULES_FILE")
+	c.CSPReportOnly = p.bool("CSP_REPORT_ONLY", true)
+	if u := p.url("CSP_REPORT_URI", false); u != nil {
+		c.CSPReportURI = u.String()
+	}
+
+	c.RateLimits = map[string]ratelimit.Limit{
+		"docs": p.limit("RATE_LIMIT_DOCS", "60/m"),
+	}
+	c.TrustedHops = p.int("RATE_LIMIT_TRUSTED_HOPS", 1)
+
+	if u := p.url("UAA_JWKS_URL", false); u != nil {
+		c.UAA.JWKSURL = u.String()
+	}
+	c.UAA.Issuer = p.get("UAA_ISSUER")
+	c.UAA.Audience = p.get("UAA_AUDIENCE")
+	if c.UAA.JWKSURL == "" && (c.UAA.Issuer != "" || c.UAA.Audience != "") {
+		p.fail("UAA_JWKS_URL", "required when UAA_ISSUER or UAA_AUDIENCE is set")
+	}
+
+	if u := p.url("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", false); u != nil {
+		c.TracesEndpoint = u.String()
+	}
+
+	if err := errors.Join(p.errs...); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
 
-	port := os.Getenv("PORT")
-	p, err := strconv.ParseUint(port, 10, 16)
+// ParseAWS reads only the AWS endpoint overrides and the SNS signing
+// certificate domain from vals, validated as in [Parse], for commands that
+// don't need the rest of the configuration.
+func ParseAWS(vals Values) (endpoints AWSEndpoints, certDomain string, err error) {
+	p := parser{vals: vals}
+	endpoints, certDomain = p.aws()
+	if err := errors.Join(p.errs...); err != nil {
+		return AWSEndpoints{}, "", err
+	}
+	return endpoints, certDomain, nil
+}
+
+// aws reads the AWS endpoint overrides and the SNS signing certificate
+// domain, which defaults to the host of the SNS endpoint override.
+func (p *parser) aws() (endpoints AWSEndpoints, certDomain string) {
+	for _, e := range []struct {
+		key   string
+		field *string
+	}{
+		{"AWS_ENDPOINT_URL_SES", &endpoints.SES},
+		{"AWS_ENDPOINT_URL_SNS", &endpoints.SNS},
+	} {
+		if u := p.url(e.key, false); u != nil {
+			*e.field = u.String()
+		}
+	}
+	certDomain = p.get("SNS_SIGNING_CERT_DOMAIN")
+	if strings.Contains(certDomain, "/") {
+		p.fail("SNS_SIGNING_CERT_DOMAIN", "must be a bare host name, without a scheme or path, got '%v'", certDomain)
+	}
+	if certDomain == "" && endpoints.SNS != "" {
+		u, _ := url.Parse(endpoints.SNS)
+		certDomain = u.Host
+	}
+	return endpoints, certDomain
+}
+
+// parser collects validation errors while reading values, so all of them can be
+// reported at once.
+type parser struct {
+	vals Values
+	errs []error
+}
+
+func (p *parser) fail(key string, format string, args ...any) {
+	p.errs = append(p.errs, fmt.Errorf("invalid %v: %v", key, fmt.Sprintf(format, args...)))
+}
+
+func (p *parser) get(key string) string {
+	return p.vals[key].Value
+}
+
+func (p *parser) required(key string) string {
+	v := p.get(key)
+	if v == "" {
+		p.fail(key, "required")
+	}
+	return v
+}
+
+// list parses the value of key as a comma-separated list. It returns nil if the
+// key is unset.
+func (p *parser) list(key string) []string {
+	return splitList(p.get(key))
+}
+
+// splitList splits v on commas, dropping empty items.
+func splitList(v string) []string {
+	if v == "" {
+		return nil
+	}
+	var l []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			l = append(l, s)
+		}
+	}
+	return l
+}
+
+func (p *parser) port(key string, required bool) uint16 {
+	v := p.get(key)
+	if v == "" {
+		if required {
+			p.fail(key, "required")
+		}
+		return 0
+	}
+	n, err := strconv.ParseUint(v, 10, 16)
 	if err != nil {
-		return Config{}, fmt.Errorf("invalid PORT: '%w'", err)
+		p.fail(key, "'%v'", err)
+		return 0
 	}
-	c.Port = uint16(p)
+	return uint16(n)
+}
 
-	brokerURL := os.Getenv("BROKER_URL")
-	u, err := url.Parse(brokerURL)
+// bool parses the value of key as a boolean, like "true" or "0". It returns def
+// if the key is unset.
+func (p *parser) bool(key string, def bool) bool {
+	v := p.get(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
 	if err != nil {
-		return Config{}, fmt.Errorf("invalid BROKER_URL: '%w'", err)
+		p.fail(key, "must be true or false, got '%v'", v)
+		return def
 	}
-	// Add a scheme and parse again, or else the URL will be parsed as relative and fields we need later, like Host, will be empty. See [url.Parse] docs.
-	if u.Scheme == "" {
-		brokerURL = "https://" + brokerURL
+	return b
+}
+
+// duration parses the value of key as a positive duration, like "5m". It
+// returns def if the key is unset.
+func (p *parser) duration(key string, def time.Duration) time.Duration {
+	v := p.get(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		p.fail(key, "must be a positive duration like '5m', got '%v'", v)
+		return def
+	}
+	return d
+}
+
+// int parses the value of key as a non-negative integer. It returns def if the
+// key is unset.
+func (p *parser) int(key string, def int) int {
+	v := p.get(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		p.fail(key, "must be a non-negative integer, got '%v'", v)
+		return def
 	}
-	u, err = url.Parse(brokerURL)
+	return n
+}
+
+// limit parses the value of key as a [ratelimit.Limit], or def if the key is
+// unset.
+func (p *parser) limit(key string, def string) ratelimit.Limit {
+	v := p.get(key)
+	if v == "" {
+		v = def
+	}
+	l, err := ratelimit.ParseLimit(v)
 	if err != nil {
-		return Config{}, fmt.Errorf("invalid BROKER_URL: '%w'", err)
+		p.fail(key, "%v", err)
 	}
+	return l
+}
 
-	c.BrokerURL = *u
+// basePath reads a URL path prefix, defaulting to "/", and adds the leading and
+// trailing slashes if they are missing.
+func (p *parser) basePath(key string) string {
+	v := p.get(key)
+	if v == "" {
+		return "/"
+	}
+	if strings.ContainsAny(v, "?#") || strings.Contains(v, "://") {
+		p.fail(key, "must be a path like /docs/, without a scheme, host, query or fragment, got '%v'", v)
+		return "/"
+	}
+	clean := path.Clean("/" + v)
+	if clean != "/" {
+		clean += "/"
+	}
+	return clean
+}
 
-	n := os.Getenv("CG_PLATFORM_NOTIFICATION_TOPIC_ARN")
-	if n == "" {
-		return Config{}, fmt.Errorf("invalid CG_PLATFORM_NOTIFICATION_TOPIC_ARN: '%v'", n)
+// links parses the value of key as a comma-separated list of Text=URL pairs, or
+// def if the key is unset. Each URL must be absolute, or a path starting with
+// "/". Set the key to "none" for no links.
+func (p *parser) links(key string, def string) []Link {
+	v := p.get(key)
+	if v == "" {
+		v = def
+	}
+	if v == "none" {
+		return nil
 	}
-	c.PlatformNotificationsTopicARN = n
+	var links []Link
+	for _, item := range splitList(v) {
+		text, u, ok := strings.Cut(item, "=")
+		text, u = strings.TrimSpace(text), strings.TrimSpace(u)
+		if !ok || text == "" || u == "" {
+			p.fail(key, "must be a comma-separated list of Text=URL pairs, got '%v'", item)
+			continue
+		}
+		parsed, err := url.Parse(u)
+		if err != nil || !(parsed.Scheme == "http" || parsed.Scheme == "https") && !(parsed.Scheme == "" && strings.HasPrefix(u, "/")) {
+			p.fail(key, "URL of link '%v' must be an http or https URL or a path starting with /, got '%v'", text, u)
+			continue
+		}
+		links = append(links, Link{Text: text, URL: u})
+	}
+	return links
+}
 
-	return c, nil
+// url parses the value of key as an absolute URL. If the value has no scheme,
+// https is assumed.
+func (p *parser) url(key string, required bool) *url.URL {
+	v := p.get(key)
+	if v == "" {
+		if required {
+			p.fail(key, "required")
+		}
+		return nil
+	}
+	// Add a scheme, or else the URL will be parsed as relative and fields we
+	// need later, like Host, will be empty. See [url.Parse] docs.
+	if !strings.Contains(v, "://") {
+		v = "https://" + v
+	}
+	u, err := url.Parse(v)
+	if err != nil {
+		p.fail(key, "'%v'", err)
+		return nil
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		p.fail(key, "scheme must be http or https, got '%v'", u.Scheme)
+		return nil
+	}
+	if u.Host == "" {
+		p.fail(key, "missing host in '%v'", p.get(key))
+		return nil
+	}
+	return u
 }
@@ -0,0 +1,176 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+)
+
+const (
+	// FileEnv names the environment variable that holds the path of an optional
+	// JSON config file.
+	FileEnv = "CSB_HELPER_CONFIG_FILE"
+	// ServiceEnv names the environment variable that holds the name of the
+	// user-provided service to read config from.
+	ServiceEnv = "CSB_HELPER_CONFIG_SERVICE"
+	// DefaultService is the name of the user-provided service read when
+	// ServiceEnv is not set.
+	DefaultService = "csb-helper-config"
+
+	SourceFile = "file"
+	SourceVCAP = "VCAP_SERVICES"
+	SourceEnv  = "env"
+
+	redacted = "REDACTED"
+)
+
+// Value is a configuration value and the source it came from.
+type Value struct {
+	Value  string
+	Source string
+}
+
+// Values maps configuration key names to values.
+type Values map[string]Value
+
+// merge copies every value in src into v, overwriting existing keys.
+func (v Values) merge(src map[string]string, source string) {
+	for name, val := range src {
+		v[name] = Value{Value: val, Source: source}
+	}
+}
+
+// Redacted returns the values as "KEY=value (source)" lines, sorted by key,
+// with secrets and URL passwords redacted.
+func (v Values) Redacted() []string {
+	lines := make([]string, 0, len(v))
+	for name, val := range v {
+		s := val.Value
+		if k, _ := lookupKey(name); k.Secret && s != "" {
+			s = redacted
+		} else if u, err := url.Parse(s); err == nil && u.User != nil {
+			s = u.Redacted()
+		}
+		lines = append(lines, fmt.Sprintf("%v=%v (%v)", name, s, val.Source))
+	}
+	slices.Sort(lines)
+	return lines
+}
+
+// Sources reads configuration values from every source. Later sources take
+// precedence over earlier ones:
+//
+//  1. The JSON file named by the CSB_HELPER_CONFIG_FILE environment variable, if set.
+//  2. The credentials of the Cloud Foundry user-provided service named by CSB_HELPER_CONFIG_SERVICE (default "csb-helper-config") in VCAP_SERVICES, if bound.
+//  3. Environment variables.
+//
+// The file and the service credentials are JSON objects whose keys are the same
+// as the environment variable names, like {"HOST": "services.cloud.gov"}.
+func Sources() (Values, error) {
+	vals := Values{}
+
+	if path := os.Getenv(FileEnv); path != "" {
+		f, err := FromFile(path)
+		if err != nil {
+			return nil, err
+		}
+		vals.merge(f, SourceFile)
+	}
+
+	service := os.Getenv(ServiceEnv)
+	if service == "" {
+		service = DefaultService
+	}
+	if vcap := os.Getenv("VCAP_SERVICES"); vcap != "" {
+		s, err := FromVCAPServices(vcap, service)
+		if err != nil {
+			return nil, err
+		}
+		vals.merge(s, SourceVCAP)
+	}
+
+	vals.merge(FromEnv(), SourceEnv)
+	return vals, nil
+}
+
+// FromEnv returns the value of every known key that is set in the environment.
+func FromEnv() map[string]string {
+	vals := make(map[string]string)
+	for _, k := range Keys {
+		if v, ok := os.LookupEnv(k.Name); ok {
+			vals[k.Name] = v
+		}
+	}
+	return vals
+}
+
+// FromFile reads a JSON object of configuration values from path.
+func FromFile(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+	vals, err := decodeObject(b)
+	if err != nil {
+		return nil, fmt.Errorf("parsing config file %v: %w", path, err)
+	}
+	return vals, nil
+}
+
+// FromVCAPServices returns the credentials of the user-provided service named
+// service from the VCAP_SERVICES JSON vcap. It returns no values if the service
+// is not bound.
+func FromVCAPServices(vcap string, service string) (map[string]string, error) {
+	var services map[string][]struct {
+		Name        string
+		Credentials json.RawMessage
+	}
+	if err := json.Unmarshal([]byte(vcap), &services); err != nil {
+		return nil, fmt.Errorf("parsing VCAP_SERVICES: %w", err)
+	}
+	for _, s := range services["user-provided"] {
+		if s.Name != service {
+			continue
+		}
+		vals, err := decodeObject(s.Credentials)
+		if err != nil {
+			return nil, fmt.Errorf("parsing credentials of service %v: %w", service, err)
+		}
+		return vals, nil
+	}
+	return map[string]string{}, nil
+}
+
+// decodeObject decodes a flat JSON object. Numbers and booleans are converted
+// to strings so they parse the same way as environment variables.
+func decodeObject(b []byte) (map[string]string, error) {
+	var raw map[string]any
+	d := json.NewDecoder(bytes.NewReader(b))
+	// Keep numbers as written, rather than in the e-notation of a float64.
+	d.UseNumber()
+	if err := d.Decode(&raw); err != nil {
+		return nil, err
+	}
+	if _, err := d.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("invalid character after top-level value")
+	}
+	vals := make(map[string]string, len(raw))
+	for k, v := range raw {
+		switch v := v.(type) {
+		case string:
+			vals[k] = v
+		case json.Number:
+			vals[k] = v.String()
+		case bool:
+			vals[k] = fmt.Sprint(v)
+		default:
+			return nil, fmt.Errorf("value of %v must be a string, number or boolean", k)
+		}
+	}
+	return vals, nil
+}
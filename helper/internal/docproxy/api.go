@@ -0,0 +1,167 @@
+package docproxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cloud-gov/csb/helper/internal/logging"
+	"github.com/cloud-gov/csb/helper/internal/osbapi"
+	"github.com/cloud-gov/csb/helper/internal/telemetry"
+)
+
+// apiIndex is the response of /api/services.
+type apiIndex struct {
+	// Source is where the docs came from: "catalog" or "page". Plans are only
+	// listed for the catalog.
+	Source    string       `json:"source"`
+	FetchedAt time.Time    `json:"fetched_at"`
+	Stale     bool         `json:"stale"`
+	Services  []apiService `json:"services"`
+}
+
+// apiService is a service offering, the response of /api/services/<name>.
+type apiService struct {
+	// Name is the name of the offering's page, which is the offering name
+	// unless that has characters other than lowercase letters, digits and
+	// dashes; see [slug].
+	Name string `json:"name"`
+	// OfferingName is the offering name in the catalog, for cf create-service,
+	// if it differs from Name.
+	OfferingName     string   `json:"offering_name,omitempty"`
+	DisplayName      string   `json:"display_name,omitempty"`
+	Description      string   `json:"description"`
+	DocsURL          string   `json:"docs_url"`
+	DocumentationURL string   `json:"documentation_url,omitempty"`
+	Tags             []string `json:"tags,omitempty"`
+	Bindable         *bool    `json:"bindable,omitempty"`
+	// BindingOutputs are the credentials that bindings return. They come from
+	// the broker docs page, since the catalog doesn't have them, and are
+	// omitted if the page doesn't list any or can't be fetched.
+	BindingOutputs []apiOutput `json:"binding_outputs,omitempty"`
+	// Plans is empty if the docs came from the broker docs page, which doesn't
+	// have plan IDs or parameter schemas.
+	Plans []apiPlan `json:"plans"`
+}
+
+// apiPlan is a plan of a service offering, with the parameters of each
+// operation, keyed by "provision", "update" and "bind".
+type apiPlan struct {
+	ID          string                        `json:"id"`
+	Name        string                        `json:"name"`
+	DisplayName string                        `json:"display_name,omitempty"`
+	Description string                        `json:"description"`
+	Free        *bool                         `json:"free,omitempty"`
+	Parameters  map[string][]osbapi.Parameter `json:"parameters"`
+}
+
+// catalogAPI describes the offerings in catalog for the API, sorted by name
+// like the service pages, which are under basePath. They are named like their
+// pages.
+func catalogAPI(catalog osbapi.Catalog, basePath string) []apiService {
+	// Name the offerings in the order [pages.RenderCatalog] renders them, so
+	// repeated names get the same suffixes as the pages.
+	offerings := slices.Clone(catalog.Services)
+	slices.SortFunc(offerings, func(a, b osbapi.Service) int { return strings.Compare(a.Name, b.Name) })
+	names := make([]string, len(offerings))
+	for i, s := range offerings {
+		names[i] = slug(s.Name)
+	}
+	uniqueNames(names)
+
+	services := make([]apiService, 0, len(offerings))
+	for i, s := range offerings {
+		svc := apiService{
+			Name:             names[i],
+			DisplayName:      s.Metadata.DisplayName,
+			Description:      s.Description,
+			DocsURL:          basePath + servicePath(names[i]),
+			DocumentationURL: s.Metadata.DocumentationURL,
+			Tags:             s.Tags,
+			Bindable:         &s.Bindable,
+			Plans:            make([]apiPlan, 0, len(s.Plans)),
+		}
+		for _, p := range s.Plans {
+			plan := apiPlan{
+				ID:          p.ID,
+				Name:        p.Name,
+				DisplayName: p.Metadata.DisplayName,
+				Description: p.Description,
+				Free:        p.Free,
+				Parameters:  map[string][]osbapi.Parameter{},
+			}
+			for _, t := range p.ParameterTables() {
+				plan.Parameters[strings.ToLower(t.Operation)] = t.Parameters
+			}
+			svc.Plans = append(svc.Plans, plan)
+		}
+		if s.Name != svc.Name {
+			svc.OfferingName = s.Name
+		}
+		services = append(services, svc)
+	}
+	return services
+}
+
+// pageAPI describes the offerings found in the broker docs page for the API,
+// with their pages under basePath. Only their names and summaries are known.
+func pageAPI(services []service, basePath string) []apiService {
+	api := make([]apiService, 0, len(services))
+	for _, s := range services {
+		api = append(api, apiService{
+			Name:        s.Name,
+			DisplayName: s.Title,
+			Description: s.Summary,
+			DocsURL:     basePath + servicePath(s.Name),
+			Plans:       []apiPlan{},
+		})
+	}
+	return api
+}
+
+// HandleAPI serves the docs as JSON: the list of offerings, or the offering
+// that is the "name" path value, if any. The data is public, so any origin may
+// read it.
+func (d *Docs) HandleAPI() http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := telemetry.StartServerSpan(r, tracer, "GET /api/services")
+			defer span.End()
+
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			doc, stale, err := d.cache.get(ctx)
+			if err != nil {
+				d.logger.ErrorContext(ctx, "Getting CSB docs", "error", err)
+				writeAPIError(w, r, http.StatusBadGateway, "the service broker is unavailable")
+				return
+			}
+
+			name := r.PathValue("name")
+			if name == "" {
+				writeJSON(w, http.StatusOK, apiIndex{Source: doc.source, FetchedAt: doc.fetchedAt, Stale: stale, Services: doc.api})
+				return
+			}
+			i := slices.IndexFunc(doc.api, func(s apiService) bool { return s.Name == name })
+			if i < 0 {
+				writeAPIError(w, r, http.StatusNotFound, "no service named "+name)
+				return
+			}
+			writeJSON(w, http.StatusOK, doc.api[i])
+		},
+	)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, r *http.Request, code int, msg string) {
+	writeJSON(w, code, map[string]string{
+		"error":      msg,
+		"request_id": logging.RequestID(r.Context()),
+	})
+}
@@ -0,0 +1,211 @@
+package docproxy_test
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cloud-gov/csb/helper/internal/config"
+	"github.com/cloud-gov/csb/helper/internal/docproxy"
+)
+
+// handleAPI serves the docs API for the broker upstream, with the test broker
+// credentials if password is set.
+func handleAPI(t *testing.T, upstream *httptest.Server, password string) http.Handler {
+	t.Helper()
+	u, err := url.Parse(upstream.URL + "/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := config.Config{BrokerURL: *u, DocsCacheTTL: time.Hour}
+	if password != "" {
+		c.BrokerUsername, c.BrokerPassword = "broker", password
+	}
+	api := docproxy.NewDocs(slog.New(slog.NewTextHandler(io.Discard, nil)), c, docproxy.DefaultRules(), testManifest).HandleAPI()
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/services", api)
+	mux.Handle("GET /api/services/{name}", api)
+	return mux
+}
+
+// TestAPIGolden checks the API response for testdata/catalog.json against
+// testdata/catalog/aws-ses.golden.json. Run with -update after an intended
+// change.
+func TestAPIGolden(t *testing.T) {
+	upstream := broker(t, 0)
+	defer upstream.Close()
+	rec := get(handleAPI(t, upstream, "secret"), "/api/services/aws-ses")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %v, got %v: %v", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("expected any origin to be allowed, got %q", got)
+	}
+
+	var v any
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join("testdata", "catalog", "aws-ses.golden.json")
+	if *update {
+		if err := os.WriteFile(file, append(got, '\n'), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got)+"\n" != string(want) {
+		t.Errorf("output differs from %v. Run go test -update to accept it.\ngot:\n%s", file, got)
+	}
+}
+
+func TestAPI(t *testing.T) {
+	type index struct {
+		Source   string `json:"source"`
+		Services []struct {
+			Name           string `json:"name"`
+			DocsURL        string `json:"docs_url"`
+			BindingOutputs []struct {
+				Name string `json:"name"`
+			} `json:"binding_outputs"`
+			Plans []struct {
+				ID string `json:"id"`
+			} `json:"plans"`
+		} `json:"services"`
+	}
+	decode := func(t *testing.T, rec *httptest.ResponseRecorder) index {
+		t.Helper()
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %v, got %v: %v", http.StatusOK, rec.Code, rec.Body.String())
+		}
+		var i index
+		if err := json.Unmarshal(rec.Body.Bytes(), &i); err != nil {
+			t.Fatal(err)
+		}
+		return i
+	}
+
+	t.Run("catalog", func(t *testing.T) {
+		upstream := broker(t, 0)
+		defer upstream.Close()
+		i := decode(t, get(handleAPI(t, upstream, "secret"), "/api/services"))
+		if i.Source != "catalog" || len(i.Services) != 2 || i.Services[0].Name != "aws-s3" || i.Services[1].Plans[0].ID == "" {
+			t.Errorf("expected both offerings from the catalog, sorted, with plan IDs, got %+v", i)
+		}
+	})
+
+	t.Run("docs page fallback", func(t *testing.T) {
+		upstream := broker(t, 0)
+		defer upstream.Close()
+		i := decode(t, get(handleAPI(t, upstream, ""), "/api/services"))
+		if i.Source != "page" || len(i.Services) != 2 || i.Services[0].DocsURL != "/services/aws-ses" {
+			t.Errorf("expected the offerings from the docs page, got %+v", i)
+		}
+		if outputs := i.Services[0].BindingOutputs; len(outputs) != 2 || outputs[0].Name != "smtp_user" {
+			t.Errorf("expected the binding outputs from the docs page, got %+v", outputs)
+		}
+	})
+
+	t.Run("catalog without docs page", func(t *testing.T) {
+		b := broker(t, 0)
+		defer b.Close()
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/docs" {
+				http.Error(w, "docs unavailable", http.StatusInternalServerError)
+				return
+			}
+			b.Config.Handler.ServeHTTP(w, r)
+		}))
+		defer upstream.Close()
+		i := decode(t, get(handleAPI(t, upstream, "secret"), "/api/services"))
+		if i.Source != "catalog" || len(i.Services) != 2 {
+			t.Errorf("expected the offerings from the catalog, got %+v", i)
+		}
+		for _, s := range i.Services {
+			if s.BindingOutputs != nil {
+				t.Errorf("expected no binding outputs without the docs page, got %+v", s.BindingOutputs)
+			}
+		}
+	})
+
+	t.Run("unknown service", func(t *testing.T) {
+		upstream := broker(t, 0)
+		defer upstream.Close()
+		rec := get(handleAPI(t, upstream, "secret"), "/api/services/aws-nope")
+		if rec.Code != http.StatusNotFound || rec.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("expected a JSON 404, got %v %v", rec.Code, rec.Header().Get("Content-Type"))
+		}
+	})
+
+	t.Run("broker down", func(t *testing.T) {
+		upstream := broker(t, 0)
+		upstream.Close()
+		rec := get(handleAPI(t, upstream, "secret"), "/api/services")
+		if rec.Code != http.StatusBadGateway || rec.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("expected a JSON 502, got %v %v", rec.Code, rec.Header().Get("Content-Type"))
+		}
+	})
+}
+
+func TestAPINamesMatchPages(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/catalog":
+			io.WriteString(w, `{"services": [{"id": "1", "name": "AWS.SES", "description": "Email.", "plans": []}]}`)
+		case "/docs":
+			io.WriteString(w, `<html><body><div><h1><code>AWS.SES</code></h1><p>Email.</p>
+<p><strong>Response Parameters</strong></p><ul><li><code>smtp_user</code> <i>string</i>: SMTP user name.</li></ul>
+</div></body></html>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+	u, err := url.Parse(upstream.URL + "/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := config.Config{BrokerURL: *u, DocsCacheTTL: time.Hour, BrokerUsername: "broker", BrokerPassword: "secret"}
+	d := docproxy.NewDocs(slog.New(slog.NewTextHandler(io.Discard, nil)), c, docproxy.DefaultRules(), testManifest)
+	mux := http.NewServeMux()
+	mux.Handle("GET /services/{name}", d.HandleDocs())
+	mux.Handle("GET /api/services/{name}", d.HandleAPI())
+
+	rec := get(mux, "/api/services/aws-ses")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %v, got %v: %v", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var svc struct {
+		Name         string `json:"name"`
+		OfferingName string `json:"offering_name"`
+		DocsURL      string `json:"docs_url"`
+		Outputs      []struct {
+			Name string `json:"name"`
+		} `json:"binding_outputs"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &svc); err != nil {
+		t.Fatal(err)
+	}
+	if svc.Name != "aws-ses" || svc.OfferingName != "AWS.SES" || svc.DocsURL != "/services/aws-ses" {
+		t.Errorf("expected the offering to be named like its page, got %+v", svc)
+	}
+	if len(svc.Outputs) != 1 || svc.Outputs[0].Name != "smtp_user" {
+		t.Errorf("expected the binding outputs from the offering's section of the docs page, got %+v", svc.Outputs)
+	}
+	if rec := get(mux, svc.DocsURL); rec.Code != http.StatusOK {
+		t.Errorf("expected docs_url to serve the offering's page, got status %v", rec.Code)
+	}
+}
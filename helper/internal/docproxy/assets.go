@@ -0,0 +1,175 @@
+package docproxy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloud-gov/csb/helper/internal/metrics"
+)
+
+// assetMaxAge is how long browsers may use a cached asset without revalidating
+// it. Asset URLs don't change when their content does, so this is a week rather
+// than forever; after that, the ETag makes revalidation cheap.
+const assetMaxAge = 7 * 24 * time.Hour
+
+// assetTypes are the content types of asset extensions whose type in the system
+// MIME tables varies, or is missing, between platforms.
+var assetTypes = map[string]string{
+	".css":   "text/css; charset=utf-8",
+	".js":    "text/javascript; charset=utf-8",
+	".json":  "application/json",
+	".svg":   "image/svg+xml",
+	".ico":   "image/vnd.microsoft.icon",
+	".woff2": "font/woff2",
+}
+
+// precompressed maps the extensions of precompressed variants to their content
+// coding, in order of preference.
+var precompressed = []struct {
+	ext    string
+	coding string
+}{
+	{".br", "br"},
+	{".gz", "gzip"},
+}
+
+// encoding is one representation of an asset: the file itself, or a
+// precompressed variant.
+type encoding struct {
+	coding  string
+	content []byte
+	etag    string
+}
+
+// asset is an embedded file, with its precompressed variants before the file
+// itself, in order of preference.
+type asset struct {
+	contentType string
+	encodings   []encoding
+}
+
+// HandleAssets serves the files in fsys, at their path in fsys. Every file is
+// read and hashed once, up front, for its ETag. A file like styles.css.br or
+// styles.css.gz is not served at its own path, but in place of styles.css to
+// clients that accept that content coding. Files that don't exist are 404s.
+func HandleAssets(logger *slog.Logger, fsys fs.FS) (http.Handler, error) {
+	assets := map[string]*asset{}
+	variants := map[string][]encoding{}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(content)
+		enc := encoding{content: content, etag: strconv.Quote(hex.EncodeToString(sum[:16]))}
+		for _, p := range precompressed {
+			if base, ok := strings.CutSuffix(name, p.ext); ok {
+				enc.coding = p.coding
+				variants[base] = append(variants[base], enc)
+				return nil
+			}
+		}
+		assets[name] = &asset{contentType: contentType(name, content), encodings: []encoding{enc}}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading assets: %w", err)
+	}
+	for base, encs := range variants {
+		a, ok := assets[base]
+		if !ok {
+			return nil, fmt.Errorf("reading assets: precompressed variant of %v, which doesn't exist", base)
+		}
+		var preferred []encoding
+		for _, p := range precompressed {
+			for _, e := range encs {
+				if e.coding == p.coding {
+					preferred = append(preferred, e)
+				}
+			}
+		}
+		a.encodings = append(preferred, a.encodings...)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+		a, ok := assets[name]
+		if !ok {
+			metrics.AssetErrors.WithLabelValues("not_found").Inc()
+			logger.WarnContext(r.Context(), "asset not found", "path", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+
+		enc := a.encodings[len(a.encodings)-1]
+		if len(a.encodings) > 1 {
+			w.Header().Add("Vary", "Accept-Encoding")
+			accept := r.Header.Get("Accept-Encoding")
+			for _, e := range a.encodings[:len(a.encodings)-1] {
+				if acceptsEncoding(accept, e.coding) {
+					enc = e
+					break
+				}
+			}
+		}
+		if enc.coding != "" {
+			w.Header().Set("Content-Encoding", enc.coding)
+		}
+		w.Header().Set("Content-Type", a.contentType)
+		w.Header().Set("ETag", enc.etag)
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(assetMaxAge.Seconds())))
+		// ServeContent handles If-None-Match, HEAD and ranges.
+		http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(enc.content))
+	}), nil
+}
+
+// contentType returns the content type of the asset name, by its extension, or
+// else by sniffing its content.
+func contentType(name string, content []byte) string {
+	ext := path.Ext(name)
+	if t, ok := assetTypes[ext]; ok {
+		return t
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+	return http.DetectContentType(content)
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header value accepts
+// coding, by name or by "*", with a nonzero quality.
+func acceptsEncoding(header, coding string) bool {
+	accepted := false
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != coding && name != "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if name == coding {
+			// An explicit entry overrides "*".
+			return q > 0
+		}
+		accepted = q > 0
+	}
+	return accepted
+}
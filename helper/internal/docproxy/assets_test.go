@@ -0,0 +1,121 @@
+package docproxy_test
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/cloud-gov/csb/helper/internal/docproxy"
+)
+
+func handleAssets(t *testing.T, fsys fstest.MapFS) http.Handler {
+	t.Helper()
+	h, err := docproxy.HandleAssets(slog.New(slog.NewTextHandler(io.Discard, nil)), fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestHandleAssets(t *testing.T) {
+	h := handleAssets(t, fstest.MapFS{
+		"assets/styles.css":         {Data: []byte("body {}")},
+		"assets/styles.css.gz":      {Data: []byte("gzipped")},
+		"assets/styles.css.br":      {Data: []byte("brotli")},
+		"assets/images/logo.svg":    {Data: []byte("<svg/>")},
+		"assets/images/logo.svg.gz": {Data: []byte("gzipped svg")},
+		"assets/fonts/a.woff2":      {Data: []byte("wOF2")},
+		"assets/document":           {Data: []byte("%PDF-1.4")},
+	})
+
+	cases := []struct {
+		name           string
+		path           string
+		acceptEncoding string
+		wantStatus     int
+		wantType       string
+		wantEncoding   string
+		wantBody       string
+	}{
+		{name: "identity", path: "/assets/styles.css", wantStatus: 200, wantType: "text/css; charset=utf-8", wantBody: "body {}"},
+		{name: "brotli preferred", path: "/assets/styles.css", acceptEncoding: "gzip, deflate, br", wantStatus: 200, wantEncoding: "br", wantBody: "brotli"},
+		{name: "gzip", path: "/assets/styles.css", acceptEncoding: "gzip", wantStatus: 200, wantEncoding: "gzip", wantBody: "gzipped"},
+		{name: "brotli refused", path: "/assets/styles.css", acceptEncoding: "br;q=0, *", wantStatus: 200, wantEncoding: "gzip", wantBody: "gzipped"},
+		{name: "only variant available", path: "/assets/images/logo.svg", acceptEncoding: "br, gzip", wantStatus: 200, wantType: "image/svg+xml", wantEncoding: "gzip", wantBody: "gzipped svg"},
+		{name: "font", path: "/assets/fonts/a.woff2", wantStatus: 200, wantType: "font/woff2"},
+		{name: "sniffed type", path: "/assets/document", wantStatus: 200, wantType: "application/pdf"},
+		{name: "missing", path: "/assets/nope.css", wantStatus: 404},
+		{name: "directory", path: "/assets/images/", wantStatus: 404},
+		{name: "variant path", path: "/assets/styles.css.gz", wantStatus: 404},
+		{name: "traversal", path: "/assets/../assets/styles.css", wantStatus: 200, wantBody: "body {}"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tc.wantStatus {
+				t.Fatalf("expected status %v, got %v", tc.wantStatus, rec.Code)
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			if tc.wantType != "" && rec.Header().Get("Content-Type") != tc.wantType {
+				t.Errorf("expected Content-Type %q, got %q", tc.wantType, rec.Header().Get("Content-Type"))
+			}
+			if got := rec.Header().Get("Content-Encoding"); got != tc.wantEncoding {
+				t.Errorf("expected Content-Encoding %q, got %q", tc.wantEncoding, got)
+			}
+			if tc.wantBody != "" && rec.Body.String() != tc.wantBody {
+				t.Errorf("expected body %q, got %q", tc.wantBody, rec.Body.String())
+			}
+			if !strings.HasPrefix(rec.Header().Get("Cache-Control"), "public, max-age=") {
+				t.Errorf("expected a long-lived Cache-Control, got %q", rec.Header().Get("Cache-Control"))
+			}
+		})
+	}
+}
+
+func TestHandleAssetsRevalidation(t *testing.T) {
+	h := handleAssets(t, fstest.MapFS{
+		"assets/styles.css":    {Data: []byte("body {}")},
+		"assets/styles.css.gz": {Data: []byte("gzipped")},
+	})
+	get := func(acceptEncoding, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/assets/styles.css", nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		req.Header.Set("If-None-Match", ifNoneMatch)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	plain, gzipped := get("", "").Header().Get("ETag"), get("gzip", "").Header().Get("ETag")
+	if plain == "" || plain == gzipped {
+		t.Fatalf("expected distinct ETags for each encoding, got %q and %q", plain, gzipped)
+	}
+	if rec := get("", plain); rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("expected 304 with no body for a matching ETag, got %v", rec.Code)
+	}
+	if rec := get("", gzipped); rec.Code != http.StatusOK {
+		t.Errorf("expected 200 for the ETag of another encoding, got %v", rec.Code)
+	}
+	if vary := get("", "").Header().Get("Vary"); vary != "Accept-Encoding" {
+		t.Errorf("expected Vary: Accept-Encoding, got %q", vary)
+	}
+}
+
+func TestHandleAssetsOrphanVariant(t *testing.T) {
+	_, err := docproxy.HandleAssets(slog.New(slog.NewTextHandler(io.Discard, nil)), fstest.MapFS{"assets/gone.css.br": {Data: []byte("x")}})
+	if err == nil || !strings.Contains(err.Error(), "assets/gone.css") {
+		t.Errorf("expected an error naming the missing file, got %v", err)
+	}
+}
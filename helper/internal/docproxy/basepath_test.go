@@ -0,0 +1,91 @@
+package docproxy_test
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloud-gov/csb/helper/internal/config"
+	"github.com/cloud-gov/csb/helper/internal/docproxy"
+)
+
+func TestPublicBasePath(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `<html><head><title>CSB</title></head><body><div>
+<p id="intro">See the <a href="guide.html">guide</a>, the <a href="/terms">terms</a> and <a href="#service-aws-ses">aws-ses</a>.</p>
+<h1 id="service-aws-ses"><img src="images/ses.svg" alt=""> aws-ses</h1><p>Email.</p>
+</div></body></html>`)
+	}))
+	defer upstream.Close()
+	u, err := url.Parse(upstream.URL + "/docs/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := config.Config{BrokerURL: *u, DocsCacheTTL: time.Hour, PublicBasePath: "/docs/"}
+	d := docproxy.NewDocs(slog.New(slog.NewTextHandler(io.Discard, nil)), c, docproxy.DefaultRules(), testManifest)
+	mux := http.NewServeMux()
+	mux.Handle("/", d.HandleDocs())
+	mux.Handle("GET /services/{name}", d.HandleDocs())
+	mux.Handle("GET /search", d.HandleSearch())
+	mux.Handle("GET /api/services", d.HandleAPI())
+
+	pages := []struct {
+		path string
+		code int
+		want []string
+	}{
+		{path: "/", code: http.StatusOK, want: []string{
+			`<base href="/docs/"/>`,
+			`href="` + upstream.URL + `/docs/guide.html"`,
+			`href="` + upstream.URL + `/terms"`,
+			`href="services/aws-ses#service-aws-ses"`,
+			`<a href="services/aws-ses">aws-ses</a>`,
+		}},
+		{path: "/services/aws-ses", code: http.StatusOK, want: []string{
+			`<base href="/docs/"/>`,
+			`src="` + upstream.URL + `/docs/images/ses.svg"`,
+		}},
+		{path: "/services/aws-nope", code: http.StatusNotFound, want: []string{
+			`<base href="/docs/">`,
+			`<a href="/docs/">All services</a>`,
+		}},
+		{path: "/search?q=email", code: http.StatusOK, want: []string{
+			`<base href="/docs/">`,
+			`<a href="services/aws-ses#service-aws-ses">`,
+		}},
+	}
+	for _, p := range pages {
+		t.Run(p.path, func(t *testing.T) {
+			rec := get(mux, p.path)
+			if rec.Code != p.code {
+				t.Fatalf("expected status %v, got %v", p.code, rec.Code)
+			}
+			for _, want := range p.want {
+				if !strings.Contains(rec.Body.String(), want) {
+					t.Errorf("expected page to contain %v, got %v", want, rec.Body.String())
+				}
+			}
+		})
+	}
+
+	t.Run("API", func(t *testing.T) {
+		rec := get(mux, "/api/services")
+		var index struct {
+			Services []struct {
+				DocsURL string `json:"docs_url"`
+			} `json:"services"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&index); err != nil {
+			t.Fatal(err)
+		}
+		if len(index.Services) != 1 || index.Services[0].DocsURL != "/docs/services/aws-ses" {
+			t.Errorf("expected one service with docs_url /docs/services/aws-ses, got %+v", index.Services)
+		}
+	})
+}
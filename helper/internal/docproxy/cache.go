@@ -0,0 +1,286 @@
+package docproxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+
+	"github.com/cloud-gov/csb/helper/internal/pages"
+	"github.com/cloud-gov/csb/helper/internal/search"
+)
+
+// refreshTimeout bounds a fetch of the upstream docs. It is not tied to any one
+// page view, since other requests may be waiting on the same fetch.
+const refreshTimeout = 30 * time.Second
+
+// retryAfterFailure is how long to wait after a failed fetch before trying
+// again, so a broker that is down isn't sent a request for every page view.
+const retryAfterFailure = 10 * time.Second
+
+// Sources of a [document].
+const (
+	sourcePage    = "page"
+	sourceCatalog = "catalog"
+)
+
+// document is the rendered, modified docs, split into pages by [splitServices],
+// their search index, their description for the JSON API, and the validators
+// the broker sent with them. source is where the docs came from, since
+// validators only apply to the same source.
+type document struct {
+	pages        map[string][]byte
+	services     []service
+	index        *search.Index
+	api          []apiService
+	source       string
+	etag         string
+	lastModified string
+	fetchedAt    time.Time
+}
+
+// call is a fetch of the upstream docs that requests can wait on.
+type call struct {
+	done chan struct{}
+	doc  *document
+	err  error
+}
+
+// docCache holds the last rendered docs page. A fresh page is served from
+// memory. A stale page is still served, while one background fetch revalidates
+// it. When there is no page yet, concurrent requests share one fetch.
+type docCache struct {
+	logger   *slog.Logger
+	url      string
+	catalog  *catalogSource
+	ttl      time.Duration
+	rules    Rules
+	manifest Manifest
+	// site is the base path the docs are published under, which their links and
+	// assets are relative to, and the links for their header.
+	site pages.Site
+
+	mu       sync.Mutex
+	doc      *document
+	inflight *call
+	// failing is set when the last fetch failed, at failedAt, with failErr, so
+	// doc may be out of date.
+	failing  bool
+	failedAt time.Time
+	failErr  error
+	// expired is set when doc was kept from the cache of a previous
+	// configuration, so it is refetched on the next get regardless of its age.
+	expired bool
+}
+
+func newDocCache(logger *slog.Logger, url string, catalog *catalogSource, ttl time.Duration, rules Rules, manifest Manifest, site pages.Site) *docCache {
+	return &docCache{logger: logger, url: url, catalog: catalog, ttl: ttl, rules: rules, manifest: manifest, site: site}
+}
+
+// get returns the docs page, fetching it if there is no copy yet. Until
+// retryAfterFailure has passed since a failed fetch, get returns its error
+// instead of fetching again. stale is true if the page is a copy kept because
+// the last attempt to refresh it failed.
+func (c *docCache) get(ctx context.Context) (doc *document, stale bool, err error) {
+	c.mu.Lock()
+	doc = c.doc
+	if doc != nil {
+		stale = c.failing
+		retry := !c.failing || time.Since(c.failedAt) >= retryAfterFailure
+		if (c.expired || time.Since(doc.fetchedAt) >= c.ttl) && retry && c.inflight == nil {
+			c.start(ctx)
+		}
+		c.mu.Unlock()
+		return doc, stale, nil
+	}
+	cl := c.inflight
+	if cl == nil && c.failing && time.Since(c.failedAt) < retryAfterFailure {
+		err = c.failErr
+		c.mu.Unlock()
+		return nil, false, err
+	}
+	if cl == nil {
+		cl = c.start(ctx)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-cl.done:
+		return cl.doc, false, cl.err
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+}
+
+// seed keeps the docs cached by prev, the cache that c replaces, along with
+// whether they are stale. Their validators are dropped, so the first fetch gets
+// the docs in full and renders them with c's configuration.
+func (c *docCache) seed(prev *docCache) {
+	prev.mu.Lock()
+	doc, failing, failedAt := prev.doc, prev.failing, prev.failedAt
+	prev.mu.Unlock()
+	if doc == nil {
+		return
+	}
+	kept := *doc
+	kept.etag, kept.lastModified = "", ""
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.doc == nil {
+		c.doc, c.failing, c.failedAt, c.expired = &kept, failing, failedAt, true
+	}
+}
+
+// start begins a fetch in the background. The caller must hold c.mu.
+func (c *docCache) start(ctx context.Context) *call {
+	cl := &call{done: make(chan struct{})}
+	c.inflight = cl
+	prev := c.doc
+	// Keep the trace and request ID, but don't cancel the fetch if the request
+	// that started it goes away.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), refreshTimeout)
+	go func() {
+		defer cancel()
+		cl.doc, cl.err = c.fetch(ctx, prev)
+
+		c.mu.Lock()
+		c.failing = cl.err != nil
+		if cl.err == nil {
+			c.doc = cl.doc
+			c.expired = false
+		} else {
+			c.failedAt, c.failErr = time.Now(), cl.err
+			c.logger.ErrorContext(ctx, "refreshing CSB docs", "err", cl.err)
+		}
+		c.inflight = nil
+		c.mu.Unlock()
+		close(cl.done)
+	}()
+	return cl
+}
+
+// fetch renders the docs from the broker catalog, if credentials are set, or
+// else from the broker docs page. The docs page is also the fallback if the
+// catalog can't be fetched or rendered. If prev is set, requests are
+// conditional, and prev is reused if its source hasn't changed.
+func (c *docCache) fetch(ctx context.Context, prev *document) (*document, error) {
+	if c.catalog != nil {
+		doc, err := c.fetchCatalog(ctx, prev)
+		if err == nil {
+			return doc, nil
+		}
+		c.logger.WarnContext(ctx, "rendering docs from the broker catalog failed; falling back to the broker docs page", "err", err)
+	}
+	return c.fetchPage(ctx, prev)
+}
+
+// fetchPage gets the docs page from the broker and modifies it with c.rules.
+// Relative URLs in the page are made absolute first, since the helper serves it
+// under its own base URL.
+func (c *docCache) fetchPage(ctx context.Context, prev *document) (*document, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, unchanged, err := getConditional(req, prev, sourcePage)
+	if err != nil {
+		return nil, fmt.Errorf("getting CSB site: %w", err)
+	}
+	if unchanged != nil {
+		return unchanged, nil
+	}
+	defer resp.Body.Close()
+
+	n, err := html.Parse(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("parsing CSB response body: %w", err)
+	}
+	resolveURLs(n, resp.Request.URL)
+	c.rules.Apply(n)
+	outputs := bindingOutputs(n)
+	doc, err := c.finish(ctx, n, resp, sourcePage, nil)
+	if err != nil {
+		return nil, err
+	}
+	addOutputs(doc.api, outputs)
+	return doc, nil
+}
+
+// getConditional sends req, conditional on the validators of prev if prev came
+// from the same source. If the broker responds 304 Not Modified, it returns a
+// copy of prev to reuse instead of a response. Any status other than 200 is an
+// error.
+func getConditional(req *http.Request, prev *document, source string) (resp *http.Response, unchanged *document, err error) {
+	conditional := prev != nil && prev.source == source
+	if conditional {
+		if prev.etag != "" {
+			req.Header.Set("If-None-Match", prev.etag)
+		}
+		if prev.lastModified != "" {
+			req.Header.Set("If-Modified-Since", prev.lastModified)
+		}
+	}
+
+	resp, err = fetchUpstream(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotModified && conditional:
+		resp.Body.Close()
+		doc := *prev
+		doc.fetchedAt = time.Now()
+		return nil, &doc, nil
+	case resp.StatusCode != http.StatusOK:
+		resp.Body.Close()
+		return nil, nil, fmt.Errorf("unexpected status %v", resp.StatusCode)
+	}
+	return resp, nil, nil
+}
+
+// finish replaces remote assets in n with local copies, splits it into pages,
+// indexes them and adds the cloud.gov banner, header and footer to them, for
+// the document from source that resp returned. api describes the services for
+// the JSON API; if it is nil, they are described from the pages.
+func (c *docCache) finish(ctx context.Context, n *html.Node, resp *http.Response, source string, api []apiService) (*document, error) {
+	for _, u := range c.manifest.Rewrite(n) {
+		c.logger.WarnContext(ctx, "remote image in CSB docs has no local copy; add it to assets/manifest.json", "url", u)
+	}
+	var buf bytes.Buffer
+	if err := html.Render(&buf, n); err != nil {
+		return nil, fmt.Errorf("rendering HTML: %w", err)
+	}
+	pages, services, err := splitServices(buf.Bytes(), c.site.BasePath)
+	if err != nil {
+		return nil, fmt.Errorf("splitting CSB docs into pages: %w", err)
+	}
+	index, err := buildIndex(pages, services)
+	if err != nil {
+		return nil, fmt.Errorf("indexing CSB docs: %w", err)
+	}
+	// The chrome is added after indexing, so its text isn't searchable.
+	for name, page := range pages {
+		if pages[name], err = withChrome(page, c.site.Nav); err != nil {
+			return nil, fmt.Errorf("adding banner, header and footer: %w", err)
+		}
+	}
+	if api == nil {
+		api = pageAPI(services, c.site.BasePath)
+	}
+	return &document{
+		pages:        pages,
+		services:     services,
+		index:        index,
+		api:          api,
+		source:       source,
+		etag:         resp.Header.Get("ETag"),
+		lastModified: resp.Header.Get("Last-Modified"),
+		fetchedAt:    time.Now(),
+	}, nil
+}
@@ -0,0 +1,280 @@
+package docproxy_test
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloud-gov/csb/helper/internal/config"
+	"github.com/cloud-gov/csb/helper/internal/docproxy"
+)
+
+const upstreamPage = `<html><head><title>CSB</title></head><body><h1> Services</h1></body></html>`
+
+func handleDocs(t *testing.T, upstream *httptest.Server, ttl time.Duration) http.Handler {
+	t.Helper()
+	u, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return docproxy.NewDocs(slog.New(slog.NewTextHandler(io.Discard, nil)), config.Config{BrokerURL: *u, DocsCacheTTL: ttl}, docproxy.DefaultRules(), testManifest).HandleDocs()
+}
+
+// get requests path from h. The path defaults to "/".
+func get(h http.Handler, path ...string) *httptest.ResponseRecorder {
+	p := "/"
+	if len(path) > 0 {
+		p = path[0]
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, p, nil))
+	return rec
+}
+
+func TestDocsCache(t *testing.T) {
+	t.Run("fresh page is served from the cache", func(t *testing.T) {
+		var hits atomic.Int32
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+			io.WriteString(w, upstreamPage)
+		}))
+		defer upstream.Close()
+		h := handleDocs(t, upstream, time.Hour)
+
+		for range 3 {
+			rec := get(h)
+			if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Services Reference | cloud.gov") {
+				t.Fatalf("expected the modified page, got status %v: %v", rec.Code, rec.Body.String())
+			}
+		}
+		if n := hits.Load(); n != 1 {
+			t.Errorf("expected 1 upstream request, got %v", n)
+		}
+	})
+
+	t.Run("concurrent misses share one fetch", func(t *testing.T) {
+		var hits atomic.Int32
+		release := make(chan struct{})
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits.Add(1)
+			<-release
+			io.WriteString(w, upstreamPage)
+		}))
+		defer upstream.Close()
+		h := handleDocs(t, upstream, time.Hour)
+
+		var wg sync.WaitGroup
+		codes := make([]int, 10)
+		for i := range codes {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				codes[i] = get(h).Code
+			}()
+		}
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		for i, code := range codes {
+			if code != http.StatusOK {
+				t.Errorf("request %v got status %v", i, code)
+			}
+		}
+		if n := hits.Load(); n != 1 {
+			t.Errorf("expected 1 upstream request, got %v", n)
+		}
+	})
+
+	t.Run("stale page is served while it is revalidated", func(t *testing.T) {
+		revalidated := make(chan string, 1)
+		var slow atomic.Bool
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if inm := r.Header.Get("If-None-Match"); inm != "" {
+				if slow.Load() {
+					time.Sleep(200 * time.Millisecond)
+				}
+				revalidated <- inm
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			io.WriteString(w, upstreamPage)
+		}))
+		defer upstream.Close()
+		h := handleDocs(t, upstream, time.Nanosecond)
+
+		first := get(h).Body.String()
+		slow.Store(true)
+		start := time.Now()
+		rec := get(h)
+		if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+			t.Errorf("stale page took %v, so it waited for the upstream", elapsed)
+		}
+		if rec.Body.String() != first {
+			t.Error("expected the stale page")
+		}
+		select {
+		case inm := <-revalidated:
+			if inm != `"v1"` {
+				t.Errorf("expected If-None-Match %q, got %q", `"v1"`, inm)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("stale page was not revalidated")
+		}
+	})
+
+	t.Run("upstream error without a cached page", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer upstream.Close()
+
+		if rec := get(handleDocs(t, upstream, time.Hour)); rec.Code != http.StatusBadGateway {
+			t.Errorf("expected status %v, got %v", http.StatusBadGateway, rec.Code)
+		}
+	})
+}
+
+func TestDocsBrokerDown(t *testing.T) {
+	t.Run("cached page is served with a notice", func(t *testing.T) {
+		var down atomic.Bool
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if down.Load() {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			io.WriteString(w, upstreamPage)
+		}))
+		defer upstream.Close()
+		h := handleDocs(t, upstream, time.Nanosecond)
+
+		if rec := get(h); strings.Contains(rec.Body.String(), "cg-notice") {
+			t.Fatal("fresh page has a stale notice")
+		}
+		down.Store(true)
+
+		// The first request after the broker goes down starts the refresh that
+		// finds out.
+		deadline := time.Now().Add(time.Second)
+		for {
+			rec := get(h)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %v, got %v", http.StatusOK, rec.Code)
+			}
+			body := rec.Body.String()
+			if strings.Contains(body, "cg-notice") {
+				if !strings.Contains(body, "Services Reference | cloud.gov") {
+					t.Error("expected the cached page with the notice")
+				}
+				if strings.Index(body, "cg-notice") < strings.Index(body, "</header>") {
+					t.Error("expected the notice after the cloud.gov header")
+				}
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("no stale notice after the refresh failed")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+
+	t.Run("outage page without a cached page", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		upstream.Close()
+
+		rec := get(handleDocs(t, upstream, time.Hour))
+		if rec.Code != http.StatusBadGateway {
+			t.Errorf("expected status %v, got %v", http.StatusBadGateway, rec.Code)
+		}
+		body := rec.Body.String()
+		for _, want := range []string{"temporarily unavailable", `<base href="/">`, `href="assets/styles.css"`, "cloudgov.statuspage.io"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("expected outage page to contain %q", want)
+			}
+		}
+	})
+
+	t.Run("failed fetch is not retried right away without a cached page", func(t *testing.T) {
+		var fetches atomic.Int32
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fetches.Add(1)
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer upstream.Close()
+		h := handleDocs(t, upstream, time.Hour)
+
+		for range 3 {
+			if rec := get(h); rec.Code != http.StatusBadGateway {
+				t.Errorf("expected status %v, got %v", http.StatusBadGateway, rec.Code)
+			}
+		}
+		if n := fetches.Load(); n != 1 {
+			t.Errorf("expected 1 fetch, got %v", n)
+		}
+	})
+}
+
+func TestDocsKeepCache(t *testing.T) {
+	var down atomic.Bool
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		io.WriteString(w, upstreamPage)
+	}))
+	defer upstream.Close()
+	u, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	newDocs := func(u url.URL) *docproxy.Docs {
+		return docproxy.NewDocs(logger, config.Config{BrokerURL: u, DocsCacheTTL: time.Hour}, docproxy.DefaultRules(), testManifest)
+	}
+
+	prev := newDocs(*u)
+	if rec := get(prev.HandleDocs()); rec.Code != http.StatusOK {
+		t.Fatalf("expected status %v, got %v", http.StatusOK, rec.Code)
+	}
+	down.Store(true)
+
+	t.Run("same broker keeps the cached docs", func(t *testing.T) {
+		next := newDocs(*u)
+		next.KeepCache(prev)
+		// The kept docs are refetched at once, and served with a notice once
+		// the refetch fails.
+		deadline := time.Now().Add(time.Second)
+		for {
+			rec := get(next.HandleDocs())
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %v, got %v", http.StatusOK, rec.Code)
+			}
+			if strings.Contains(rec.Body.String(), "cg-notice") {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("kept docs were not refetched")
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+
+	t.Run("other broker starts empty", func(t *testing.T) {
+		other := *u
+		other.Path = "/other"
+		next := newDocs(other)
+		next.KeepCache(prev)
+		if rec := get(next.HandleDocs()); rec.Code != http.StatusBadGateway {
+			t.Errorf("expected status %v, got %v", http.StatusBadGateway, rec.Code)
+		}
+	})
+}
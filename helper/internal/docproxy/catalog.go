@@ -0,0 +1,85 @@
+package docproxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/html"
+
+	"github.com/cloud-gov/csb/helper/internal/osbapi"
+	"github.com/cloud-gov/csb/helper/internal/pages"
+)
+
+// catalogSource is the broker's OSBAPI catalog, which the docs can be rendered
+// from with the helper's own templates rather than by modifying the markup of
+// the broker's docs page.
+type catalogSource struct {
+	brokerURL url.URL
+	username  string
+	password  string
+}
+
+// fetchCatalog gets the catalog from the broker and renders it with
+// [pages.RenderCatalog].
+func (c *docCache) fetchCatalog(ctx context.Context, prev *document) (*document, error) {
+	req, err := osbapi.NewCatalogRequest(ctx, c.catalog.brokerURL, c.catalog.username, c.catalog.password)
+	if err != nil {
+		return nil, err
+	}
+	resp, unchanged, err := getConditional(req, prev, sourceCatalog)
+	if err != nil {
+		return nil, fmt.Errorf("getting CSB catalog: %w", err)
+	}
+	if unchanged != nil {
+		return unchanged, nil
+	}
+	defer resp.Body.Close()
+
+	catalog, err := osbapi.DecodeCatalog(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(catalog.Services) == 0 {
+		return nil, fmt.Errorf("CSB catalog has no services")
+	}
+	rendered, err := pages.RenderCatalog(catalog)
+	if err != nil {
+		return nil, fmt.Errorf("rendering CSB catalog: %w", err)
+	}
+	n, err := html.Parse(bytes.NewReader(rendered))
+	if err != nil {
+		return nil, fmt.Errorf("parsing rendered CSB catalog: %w", err)
+	}
+	api := catalogAPI(catalog, c.site.BasePath)
+	outputs, err := c.fetchOutputs(ctx)
+	if err != nil {
+		c.logger.WarnContext(ctx, "getting binding outputs from the broker docs page failed; the API won't list them", "err", err)
+	}
+	addOutputs(api, outputs)
+	return c.finish(ctx, n, resp, sourceCatalog, api)
+}
+
+// fetchOutputs gets the broker docs page for the binding outputs of each
+// service, which the catalog doesn't have. They come from the same brokerpaks
+// as the catalog, so they are only fetched again when the catalog changes.
+func (c *docCache) fetchOutputs(ctx context.Context) (map[string][]apiOutput, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, _, err := getConditional(req, nil, sourcePage)
+	if err != nil {
+		return nil, fmt.Errorf("getting CSB site: %w", err)
+	}
+	defer resp.Body.Close()
+
+	n, err := html.Parse(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("parsing CSB response body: %w", err)
+	}
+	c.rules.Apply(n)
+	return bindingOutputs(n), nil
+}
@@ -0,0 +1,124 @@
+package docproxy_test
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloud-gov/csb/helper/internal/config"
+	"github.com/cloud-gov/csb/helper/internal/docproxy"
+)
+
+// broker serves testdata/catalog.json at /v2/catalog to requests with the test
+// credentials and API version, and testdata/docs.html at /docs. catalogStatus
+// overrides the status of catalog responses if set.
+func broker(t *testing.T, catalogStatus int) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/catalog":
+			if user, pass, ok := r.BasicAuth(); !ok || user != "broker" || pass != "secret" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if r.Header.Get("X-Broker-API-Version") == "" {
+				http.Error(w, "missing X-Broker-API-Version", http.StatusPreconditionFailed)
+				return
+			}
+			if catalogStatus != 0 {
+				http.Error(w, "catalog unavailable", catalogStatus)
+				return
+			}
+			http.ServeFile(w, r, "testdata/catalog.json")
+		case "/docs":
+			http.ServeFile(w, r, "testdata/docs.html")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func handleCatalogDocs(t *testing.T, upstream *httptest.Server, password string) http.Handler {
+	t.Helper()
+	u, err := url.Parse(upstream.URL + "/docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := config.Config{BrokerURL: *u, BrokerUsername: "broker", BrokerPassword: password, DocsCacheTTL: time.Hour}
+	docs := docproxy.NewDocs(slog.New(slog.NewTextHandler(io.Discard, nil)), c, docproxy.DefaultRules(), testManifest)
+	mux := http.NewServeMux()
+	mux.Handle("/", docs.HandleDocs())
+	mux.Handle("GET /services/{name}", docs.HandleDocs())
+	return mux
+}
+
+// TestCatalogPagesGolden checks the pages rendered from testdata/catalog.json
+// against testdata/catalog/*.golden.html. Run with -update after an intended
+// change.
+func TestCatalogPagesGolden(t *testing.T) {
+	upstream := broker(t, 0)
+	defer upstream.Close()
+	h := handleCatalogDocs(t, upstream, "secret")
+
+	for path, golden := range map[string]string{
+		"/":                 "index",
+		"/services/aws-ses": "aws-ses",
+		"/services/aws-s3":  "aws-s3",
+	} {
+		t.Run(golden, func(t *testing.T) {
+			rec := get(h, path)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %v, got %v", http.StatusOK, rec.Code)
+			}
+			got := rec.Body.String()
+
+			file := filepath.Join("testdata", "catalog", golden+".golden.html")
+			if *update {
+				os.MkdirAll(filepath.Dir(file), 0o755)
+				if err := os.WriteFile(file, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("output differs from %v. Run go test -update to accept it.\ngot:\n%v", file, got)
+			}
+		})
+	}
+}
+
+func TestCatalogFallback(t *testing.T) {
+	cases := []struct {
+		name          string
+		password      string
+		catalogStatus int
+	}{
+		{name: "wrong credentials", password: "wrong"},
+		{name: "catalog error", password: "secret", catalogStatus: http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			upstream := broker(t, tc.catalogStatus)
+			defer upstream.Close()
+			h := handleCatalogDocs(t, upstream, tc.password)
+
+			rec := get(h)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %v, got %v", http.StatusOK, rec.Code)
+			}
+			if !strings.Contains(rec.Body.String(), `id="intro">Services available from the Cloud Service Broker.`) {
+				t.Errorf("expected the docs page to be served instead of the catalog, got %v", rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package docproxy
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/cloud-gov/csb/helper/internal/pages"
+)
+
+// withChrome returns the rendered page with [addChrome] applied.
+func withChrome(page []byte, nav []pages.Link) ([]byte, error) {
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return nil, err
+	}
+	if err := addChrome(doc, nav); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := html.Render(&buf, doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// addChrome adds the official U.S. government banner and the cloud.gov header,
+// with nav and the search box, to the start of the body of doc, and the
+// cloud.gov footer to its end, before any scripts there.
+func addChrome(doc *html.Node, nav []pages.Link) error {
+	var body *html.Node
+	walk(doc, func(n *html.Node) bool {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Body {
+			body = n
+			return true
+		}
+		return false
+	})
+	if body == nil {
+		return nil
+	}
+
+	top, bottom, err := pages.Chrome(nav)
+	if err != nil {
+		return err
+	}
+	nodes, err := html.ParseFragment(bytes.NewReader(top), body)
+	if err != nil {
+		return err
+	}
+	first := body.FirstChild
+	for _, n := range nodes {
+		body.InsertBefore(n, first)
+	}
+	nodes, err = html.ParseFragment(bytes.NewReader(bottom), body)
+	if err != nil {
+		return err
+	}
+	// Scripts at the end of the body, like the anchor redirect of the index
+	// page, stay after the footer.
+	var scripts *html.Node
+	for c := body.LastChild; c != nil; c = c.PrevSibling {
+		isSpace := c.Type == html.TextNode && strings.TrimSpace(c.Data) == ""
+		isScript := c.Type == html.ElementNode && c.DataAtom == atom.Script
+		if !isSpace && !isScript {
+			break
+		}
+		if isScript {
+			scripts = c
+		}
+	}
+	for _, n := range nodes {
+		body.InsertBefore(n, scripts)
+	}
+	return nil
+}
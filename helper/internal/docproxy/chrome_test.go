@@ -0,0 +1,61 @@
+package docproxy_test
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloud-gov/csb/helper/internal/config"
+	"github.com/cloud-gov/csb/helper/internal/docproxy"
+)
+
+func TestChrome(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "testdata/docs.html")
+	}))
+	defer upstream.Close()
+	u, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := config.Config{
+		BrokerURL:    *u,
+		DocsCacheTTL: time.Hour,
+		NavLinks:     []config.Link{{Text: "Docs", URL: "https://cloud.gov/docs/"}, {Text: "Pricing", URL: "https://cloud.gov/pricing/"}},
+	}
+	d := docproxy.NewDocs(slog.New(slog.NewTextHandler(io.Discard, nil)), c, docproxy.DefaultRules(), testManifest)
+	mux := http.NewServeMux()
+	mux.Handle("/", d.HandleDocs())
+	mux.Handle("GET /services/{name}", d.HandleDocs())
+	mux.Handle("GET /search", d.HandleSearch())
+
+	// Every page starts with the banner and the header, and ends with the
+	// footer, before any scripts.
+	layout := regexp.MustCompile(`(?s)<body>\s*<section class="cg-banner".*An official website of the United States government.*</section>\s*<header class="cg-header">.*</header>.*<footer class="cg-footer">.*</footer>\s*(<script[^>]*>[^<]*</script>)*</body>`)
+	for _, path := range []string{"/", "/services/aws-ses", "/services/aws-nope", "/search?q=ses"} {
+		t.Run(path, func(t *testing.T) {
+			body := get(mux, path).Body.String()
+			if !layout.MatchString(body) {
+				t.Errorf("expected the banner, header and footer, got %v", body)
+			}
+			for _, want := range []string{
+				`<li><a href="https://cloud.gov/docs/">Docs</a></li>`,
+				`<li><a href="https://cloud.gov/pricing/">Pricing</a></li>`,
+				`<form class="cg-search"`,
+			} {
+				if !strings.Contains(body, want) {
+					t.Errorf("expected page to contain %v", want)
+				}
+			}
+			if n := strings.Count(body, `<form class="cg-search"`); n != 1 {
+				t.Errorf("expected one search box, got %v", n)
+			}
+		})
+	}
+}
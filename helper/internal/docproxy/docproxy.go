@@ -1,57 +1,29 @@
 package docproxy
 
 import (
-	"embed"
+	"bytes"
+	"context"
+	"fmt"
 	"log/slog"
 	"net/http"
-	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/net/html"
 
 	"github.com/cloud-gov/csb/helper/internal/config"
+	"github.com/cloud-gov/csb/helper/internal/logging"
+	"github.com/cloud-gov/csb/helper/internal/metrics"
+	"github.com/cloud-gov/csb/helper/internal/pages"
+	"github.com/cloud-gov/csb/helper/internal/telemetry"
 )
 
-func serveAsset(logger *slog.Logger, w http.ResponseWriter, path string, assets embed.FS) {
-	errBody := "An error in Cloud.gov occurred while serving this asset."
-
-	asset, err := assets.ReadFile(path)
-	if err != nil {
-		http.Error(w, errBody, http.StatusInternalServerError)
-		logger.Error("failed to read asset from embedded fs", "path", path, "err", err)
-		return
-	}
-
-	ext := filepath.Ext(path)
-	var contentType string
-	switch ext {
-	case ".svg":
-		contentType = "image/svg+xml"
-	case ".ico":
-		contentType = "image/vnd.microsoft.icon"
-	case ".woff2":
-		contentType = "font/woff2"
-	case ".css":
-		contentType = "text/css; charset=utf-8"
-	default:
-		logger.Error("tried serving asset with unknown file extension, and therefore no mapped content-type", "path", path)
-		http.Error(w, errBody, http.StatusInternalServerError)
-		return
-	}
-
-	w.Header().Add("Content-Type", contentType)
-	w.Write(asset)
-}
-
-func HandleAssets(logger *slog.Logger, assets embed.FS) http.Handler {
-	return http.HandlerFunc(
-		func(w http.ResponseWriter, r *http.Request) {
-			fpath := strings.Join(strings.Split(r.URL.Path, "/")[1:], "/")
-			serveAsset(logger, w, fpath, assets)
-		},
-	)
-}
+var tracer = otel.Tracer("github.com/cloud-gov/csb/helper/internal/docproxy")
 
 // walk traverses the nodes of the HTML document tree and calls f on each node. If f
 // returns true, walk stops traversing.
@@ -67,131 +39,187 @@ func walk(n *html.Node, f func(*html.Node) bool) bool {
 	return false
 }
 
-// modifyDocument makes a series of changes to the html.Node in-place.
-func modifyDocument(n *html.Node) {
-	modifications := []func(*html.Node) bool{
-		func(n *html.Node) bool {
-			if n.Type == html.ElementNode && n.Data == "head" {
-				// Inject our custom stylesheet.
-				n.AppendChild(&html.Node{
-					Type: html.ElementNode,
-					Data: "link",
-					Attr: []html.Attribute{
-						{
-							Key: "rel",
-							Val: "stylesheet",
-						},
-						{
-							Key: "href",
-							Val: "assets/styles.css",
-						},
-					},
-				})
-				// Inject favicon.
-				n.AppendChild(&html.Node{
-					Type: html.ElementNode,
-					Data: "link",
-					Attr: []html.Attribute{
-						{
-							Key: "rel",
-							Val: "icon",
-						},
-						{
-							Key: "type",
-							Val: "image/vnd.microsoft.icon",
-						},
-						{
-							Key: "sizes",
-							Val: "192x192",
-						},
-						{
-							Key: "href",
-							Val: "assets/images/favicon.ico",
-						},
-					},
-				})
-			}
-			return false
-		},
-		func(n *html.Node) bool {
-			if n.Type == html.TextNode && n.Parent.Type == html.ElementNode && n.Parent.Data == "h1" {
-				// Trim whitespace from header, which has a leading space.
-				n.Data = strings.Trim(n.Data, " ")
-			}
-			return false
-		},
-		func(n *html.Node) bool {
-			if n.Type == html.TextNode && n.Parent.Type == html.ElementNode && n.Parent.Data == "title" {
-				// Add title that is consistent with cloud.gov page titles.
-				n.Data = "Services Reference | cloud.gov"
-			}
+// fetchUpstream sends req to the broker, recording its latency and status.
+func fetchUpstream(req *http.Request) (resp *http.Response, err error) {
+	ctx, span := tracer.Start(req.Context(), "fetchUpstream", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("url.full", req.URL.String()),
+	))
+	defer span.End()
+	defer func() { telemetry.RecordError(span, err) }()
 
-			return false
-		},
-		func(n *html.Node) bool {
-			if n.Type == html.TextNode && n.Parent.Parent.Type == html.ElementNode && n.Parent.Data == "a" {
-				cls := html.Attribute{
-					Key: "class",
-					Val: "navbar-brand",
-				}
-				if i := slices.Index(n.Parent.Attr, cls); i >= 0 {
-					// Change page title.
-					n.Data = "Services Reference"
-				}
-			}
-			return false
-		},
-		func(n *html.Node) bool {
-			if n.Type == html.ElementNode && n.Data == "img" {
-				// Replace the SES logo with a relative path. The brokerpak only compiles
-				// with a full URL (not relative), so this must be done here.
-				src := html.Attribute{
-					Key: "src",
-					Val: "https://services.cloud.gov/images/amazon-ses.svg",
-				}
-				newSrc := html.Attribute{
-					Key: "src",
-					Val: "assets/images/amazon-ses.svg",
-				}
-				if i := slices.Index(n.Attr, src); i >= 0 {
-					n.Attr[i] = newSrc
-				}
-			}
-			return false
+	start := time.Now()
+	resp, err = http.DefaultClient.Do(req.WithContext(ctx))
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+	}
+	metrics.DocproxyUpstreamDuration.WithLabelValues(status).Observe(time.Since(start).Seconds())
+	return resp, err
+}
+
+// renderOutage responds with the branded outage page, for when the docs can't
+// be fetched and there is no cached copy.
+func renderOutage(ctx context.Context, logger *slog.Logger, w http.ResponseWriter, site pages.Site) {
+	err := pages.RenderError(w, http.StatusBadGateway, pages.Error{
+		Site:      site,
+		Title:     "Services Reference is temporarily unavailable",
+		Message:   "cloud.gov couldn't get the services reference from the service broker. Try again in a few minutes.",
+		RequestID: logging.RequestID(ctx),
+		Links: []pages.Link{
+			{Text: "cloud.gov status", URL: pages.StatusURL},
+			{Text: "cloud.gov documentation", URL: "https://cloud.gov/docs/"},
 		},
+	})
+	if err != nil {
+		logger.ErrorContext(ctx, "Rendering outage page", "error", err)
+		http.Error(w, "An error in Cloud.gov occurred while getting this page.", http.StatusBadGateway)
 	}
-	walk(n, func(n *html.Node) bool {
-		for _, m := range modifications {
-			stop := m(n)
-			if stop {
-				return true
-			}
-		}
-		return false
+}
+
+// renderNotFound responds with the branded 404 page, for a service page that
+// doesn't exist.
+func renderNotFound(ctx context.Context, logger *slog.Logger, w http.ResponseWriter, name string, site pages.Site) {
+	err := pages.RenderError(w, http.StatusNotFound, pages.Error{
+		Site:      site,
+		Title:     "Service not found",
+		Message:   fmt.Sprintf("cloud.gov doesn't offer a service named %q.", name),
+		RequestID: logging.RequestID(ctx),
+		Links:     []pages.Link{{Text: "All services", URL: site.BasePath}},
 	})
+	if err != nil {
+		logger.ErrorContext(ctx, "Rendering not found page", "error", err)
+		http.NotFound(w, nil)
+	}
+}
+
+// insertNotice returns doc with content inserted after the cloud.gov header
+// added by [addChrome], so the official banner stays first. If doc has no
+// header, content is inserted at the start of its body element, or, if doc has
+// no body tag, prepended.
+func insertNotice(doc []byte, content []byte) []byte {
+	i := bytes.Index(doc, []byte("</header>"))
+	if i >= 0 {
+		i += len("</header>")
+	} else if i = bytes.Index(doc, []byte("<body")); i >= 0 {
+		if j := bytes.IndexByte(doc[i:], '>'); j >= 0 {
+			i += j + 1
+		}
+	} else {
+		i = 0
+	}
+	return slices.Concat(doc[:i], content, doc[i:])
 }
 
-func HandleDocs(logger *slog.Logger, c config.Config) http.Handler {
+// Docs serves the broker docs and the search over them from one cache.
+type Docs struct {
+	logger *slog.Logger
+	cache  *docCache
+	site   pages.Site
+}
+
+// NewDocs returns the docs from the broker at c.BrokerURL. They are rendered
+// from the broker catalog if c has broker credentials, or else modified for
+// cloud.gov by rules from the broker docs page, with remote assets replaced by
+// the local copies in manifest. Their links and assets are relative to
+// c.PublicBasePath, and their cloud.gov header links to c.NavLinks. The docs
+// are cached for c.DocsCacheTTL; see [docCache].
+func NewDocs(logger *slog.Logger, c config.Config, rules Rules, manifest Manifest) *Docs {
+	var catalog *catalogSource
+	if c.BrokerUsername != "" {
+		catalog = &catalogSource{brokerURL: c.BrokerURL, username: c.BrokerUsername, password: c.BrokerPassword}
+	}
+	site := pages.Site{BasePath: c.PublicBasePath}
+	if site.BasePath == "" {
+		site.BasePath = "/"
+	}
+	for _, l := range c.NavLinks {
+		site.Nav = append(site.Nav, pages.Link{Text: l.Text, URL: l.URL})
+	}
+	return &Docs{logger: logger, cache: newDocCache(logger, c.BrokerURL.String(), catalog, c.DocsCacheTTL, rules, manifest, site), site: site}
+}
+
+// KeepCache seeds the cache of d with the docs cached by prev, the Docs that d
+// replaces when the configuration is reloaded, if both get the docs from the
+// same broker URL. That way a reload while the broker is down still has docs to
+// serve. The kept docs are refetched on the next request, since the new
+// configuration may render them differently, and are served until that fetch
+// succeeds.
+func (d *Docs) KeepCache(prev *Docs) {
+	if prev == nil || prev.cache.url != d.cache.url {
+		return
+	}
+	d.cache.seed(prev.cache)
+}
+
+// get returns the cached docs. If there are none, it responds with the outage
+// page and returns nil.
+func (d *Docs) get(ctx context.Context, w http.ResponseWriter) (doc *document, stale bool) {
+	doc, stale, err := d.cache.get(ctx)
+	if err != nil {
+		d.logger.ErrorContext(ctx, "Getting CSB docs", "error", err)
+		renderOutage(ctx, d.logger, w, d.site)
+		return nil, false
+	}
+	return doc, stale
+}
+
+// HandleDocs serves the docs, split into an index page and a page for each
+// service; the service is the "name" path value, if any. If the broker can't be
+// reached, the cached page is served with a notice that it may be out of date,
+// or, if there is none, the branded outage page.
+func (d *Docs) HandleDocs() http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
-			resp, err := http.Get(c.BrokerURL.String())
-			if err != nil {
-				logger.Error("Getting CSB site", "error", err)
-				w.WriteHeader(http.StatusBadGateway)
+			ctx, span := telemetry.StartServerSpan(r, tracer, "GET /")
+			defer span.End()
+
+			doc, stale := d.get(ctx, w)
+			if doc == nil {
+				return
 			}
-			defer resp.Body.Close() // todo can return error
-			doc, err := html.Parse(resp.Body)
-			if err != nil {
-				logger.Error("Parsing CSB response body", "error", err)
-				w.WriteHeader(http.StatusInternalServerError)
+
+			name := r.PathValue("name")
+			body, ok := doc.pages[name]
+			if !ok {
+				renderNotFound(ctx, d.logger, w, name, d.site)
+				return
 			}
+			if stale {
+				notice, err := pages.StaleNotice(doc.fetchedAt)
+				if err != nil {
+					d.logger.ErrorContext(ctx, "Rendering stale notice", "error", err)
+				} else {
+					body = insertNotice(body, notice)
+				}
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write(body)
+		},
+	)
+}
 
-			modifyDocument(doc)
+// maxSearchResults is how many results the search page shows.
+const maxSearchResults = 20
 
-			err = html.Render(w, doc)
-			if err != nil {
-				logger.Error("Rendering HTML", "error", err)
-				w.WriteHeader(http.StatusInternalServerError)
+// HandleSearch serves the results of searching the docs for the "q" query
+// parameter.
+func (d *Docs) HandleSearch() http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := telemetry.StartServerSpan(r, tracer, "GET /search")
+			defer span.End()
+
+			doc, _ := d.get(ctx, w)
+			if doc == nil {
+				return
+			}
+			q := strings.TrimSpace(r.URL.Query().Get("q"))
+			results := doc.index.Search(q, maxSearchResults)
+			span.SetAttributes(attribute.Int("search.results", len(results)))
+			if err := pages.RenderSearch(w, pages.Search{Site: d.site, Query: q, Results: results}); err != nil {
+				d.logger.ErrorContext(ctx, "Rendering search results", "error", err)
+				http.Error(w, "An error in Cloud.gov occurred while searching.", http.StatusInternalServerError)
 			}
 		},
 	)
@@ -0,0 +1,100 @@
+package docproxy
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/cloud-gov/csb/helper/internal/search"
+)
+
+// buildIndex indexes the service pages of a document for search. Each h1, h2 or
+// h3 starts an entry that runs to the next such heading, like an offering or a
+// plan. Each table row or list item that starts with a code element, like a
+// parameter, is also an entry of its own. If the docs have no service pages,
+// the index page is one entry.
+func buildIndex(docPages map[string][]byte, services []service) (*search.Index, error) {
+	var entries []search.Entry
+	if len(services) == 0 {
+		doc, err := html.Parse(bytes.NewReader(docPages[indexPage]))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, search.Entry{Title: "Services Reference", Text: normalizeSpace(text(doc))})
+		return search.New(entries), nil
+	}
+
+	for _, s := range services {
+		_, container, _, err := parseSections(docPages[s.Name])
+		if err != nil {
+			return nil, err
+		}
+		if container == nil {
+			continue
+		}
+		page := servicePath(s.Name)
+		var heading *search.Entry
+		var content []string
+		done := func() {
+			if heading != nil {
+				heading.Text = normalizeSpace(strings.Join(content, " "))
+				entries = append(entries, *heading)
+			}
+		}
+		for c := container.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && (c.DataAtom == atom.H1 || c.DataAtom == atom.H2 || c.DataAtom == atom.H3) {
+				done()
+				heading = &search.Entry{Service: s.Name, Title: normalizeSpace(text(c)), URL: page}
+				if id, ok := attr(c, "id"); ok {
+					heading.URL = page + "#" + id
+				}
+				content = nil
+				continue
+			}
+			content = append(content, text(c))
+			if heading == nil {
+				continue
+			}
+			walk(c, func(n *html.Node) bool {
+				if n.Type != html.ElementNode || (n.DataAtom != atom.Tr && n.DataAtom != atom.Li) {
+					return false
+				}
+				if name := leadingCode(n); name != "" {
+					entries = append(entries, search.Entry{Service: s.Name, Title: name, URL: heading.URL, Text: normalizeSpace(text(n))})
+				}
+				return false
+			})
+		}
+		done()
+	}
+	return search.New(entries), nil
+}
+
+// leadingCode returns the text of the code element that n starts with, if any,
+// looking into n's first cell if n is a table row.
+func leadingCode(n *html.Node) string {
+	c := firstChild(n)
+	if c != nil && c.Type == html.ElementNode && c.DataAtom == atom.Td {
+		c = firstChild(c)
+	}
+	if c != nil && c.Type == html.ElementNode && c.DataAtom == atom.Code {
+		return strings.TrimSpace(text(c))
+	}
+	return ""
+}
+
+// firstChild returns the first child of n that isn't whitespace.
+func firstChild(n *html.Node) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.TextNode || strings.TrimSpace(c.Data) != "" {
+			return c
+		}
+	}
+	return nil
+}
+
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
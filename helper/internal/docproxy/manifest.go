@@ -0,0 +1,75 @@
+package docproxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"slices"
+
+	"golang.org/x/net/html"
+)
+
+// Manifest maps the URLs of remote assets, like brokerpak logos from the
+// catalog's image_url, to local copies. Local paths are relative to the assets
+// directory, like "images/amazon-ses.svg".
+type Manifest map[string]string
+
+// LoadManifest reads the manifest file name from fsys. Every local path must
+// exist in fsys, relative to the manifest's directory.
+func LoadManifest(fsys fs.FS, name string) (Manifest, error) {
+	b, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("reading asset manifest: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("parsing asset manifest %v: %w", name, err)
+	}
+	var errs []error
+	dir := path.Dir(name)
+	for remote, local := range m {
+		if _, err := fs.Stat(fsys, path.Join(dir, local)); err != nil {
+			errs = append(errs, fmt.Errorf("asset manifest %v: local copy of %v: %w", name, remote, err))
+		}
+	}
+	return m, errors.Join(errs...)
+}
+
+// Rewrite points the src of every img and the href of every link in n that has
+// a local copy in m to that copy. It returns the remote image URLs that have no
+// local copy, so they can be added to the manifest.
+func (m Manifest) Rewrite(n *html.Node) (unmapped []string) {
+	walk(n, func(n *html.Node) bool {
+		if n.Type != html.ElementNode {
+			return false
+		}
+		var key string
+		switch n.Data {
+		case "img":
+			key = "src"
+		case "link":
+			key = "href"
+		default:
+			return false
+		}
+		v, ok := attr(n, key)
+		if !ok {
+			return false
+		}
+		if local, ok := m[v]; ok {
+			setAttr(n, key, "assets/"+local)
+		} else if n.Data == "img" && isRemote(v) && !slices.Contains(unmapped, v) {
+			unmapped = append(unmapped, v)
+		}
+		return false
+	})
+	return unmapped
+}
+
+func isRemote(ref string) bool {
+	u, err := url.Parse(ref)
+	return err == nil && u.Host != ""
+}
@@ -0,0 +1,54 @@
+package docproxy_test
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"golang.org/x/net/html"
+
+	"github.com/cloud-gov/csb/helper/internal/docproxy"
+)
+
+func TestLoadManifest(t *testing.T) {
+	fsys := fstest.MapFS{
+		"assets/images/logo.svg": {Data: []byte("<svg/>")},
+		"assets/manifest.json": {Data: []byte(`{
+			"https://example.com/logo.svg": "images/logo.svg",
+			"https://example.com/missing.svg": "images/missing.svg"
+		}`)},
+	}
+	_, err := docproxy.LoadManifest(fsys, "assets/manifest.json")
+	if err == nil || !strings.Contains(err.Error(), "https://example.com/missing.svg") {
+		t.Errorf("expected an error about the missing local copy, got %v", err)
+	}
+}
+
+func TestManifestRewrite(t *testing.T) {
+	m := docproxy.Manifest{
+		"https://example.com/logo.svg": "images/logo.svg",
+		"https://example.com/icon.ico": "images/icon.ico",
+	}
+	doc := `<html><head><link rel="icon" href="https://example.com/icon.ico"><link rel="stylesheet" href="https://cdn.example.com/site.css"></head>` +
+		`<body><img src="https://example.com/logo.svg"><img src="https://example.com/other.png"><img src="https://example.com/other.png"><img src="local.png"></body></html>`
+
+	n, err := html.Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unmapped := m.Rewrite(n)
+	var buf bytes.Buffer
+	html.Render(&buf, n)
+	got := buf.String()
+
+	for _, want := range []string{`href="assets/images/icon.ico"`, `src="assets/images/logo.svg"`, `href="https://cdn.example.com/site.css"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected output to contain %v, got %v", want, got)
+		}
+	}
+	if !slices.Equal(unmapped, []string{"https://example.com/other.png"}) {
+		t.Errorf("expected only the remote image without a local copy to be reported once, got %v", unmapped)
+	}
+}
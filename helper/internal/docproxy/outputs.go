@@ -0,0 +1,85 @@
+package docproxy
+
+import (
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// outputHeadings are the labels, compared case-insensitively, that the broker
+// docs page puts before the list of a service's binding outputs.
+var outputHeadings = []string{"Response Parameters", "Binding Outputs"}
+
+// apiOutput is a credential that bindings of a service offering return, as
+// listed on the broker docs page.
+type apiOutput struct {
+	Name        string `json:"name"`
+	Type        string `json:"type,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// bindingOutputs finds the binding outputs of each service on the broker docs
+// page doc, keyed by service name like the service pages. A service lists them
+// in a ul right after an element whose text is one of outputHeadings, with an
+// item for each output: its name in a code element, its type in an i or em
+// element, and then its description.
+func bindingOutputs(doc *html.Node) map[string][]apiOutput {
+	_, sections := findSections(doc)
+	outputs := map[string][]apiOutput{}
+	for _, s := range sections {
+		heading := false
+		for n := s.first; n != s.last.NextSibling; n = n.NextSibling {
+			if n.Type != html.ElementNode {
+				continue
+			}
+			if heading && n.DataAtom == atom.Ul {
+				outputs[s.Name] = append(outputs[s.Name], listOutputs(n)...)
+			}
+			label := normalizeSpace(text(n))
+			heading = slices.ContainsFunc(outputHeadings, func(h string) bool { return strings.EqualFold(h, label) })
+		}
+	}
+	return outputs
+}
+
+// listOutputs reads the outputs in the items of ul. Items without a name are
+// skipped.
+func listOutputs(ul *html.Node) []apiOutput {
+	var outputs []apiOutput
+	for li := ul.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode || li.DataAtom != atom.Li {
+			continue
+		}
+		var o apiOutput
+		var desc strings.Builder
+		for c := li.FirstChild; c != nil; c = c.NextSibling {
+			switch {
+			case o.Name == "" && c.Type == html.ElementNode && c.DataAtom == atom.Code:
+				o.Name = normalizeSpace(text(c))
+			case o.Type == "" && c.Type == html.ElementNode && (c.DataAtom == atom.I || c.DataAtom == atom.Em):
+				o.Type = normalizeSpace(text(c))
+			default:
+				desc.WriteString(text(c))
+			}
+		}
+		if o.Name == "" {
+			continue
+		}
+		// The broker separates the description from the name and type with a
+		// colon or a dash.
+		o.Description = strings.TrimSpace(strings.TrimLeft(normalizeSpace(desc.String()), ":-"))
+		outputs = append(outputs, o)
+	}
+	return outputs
+}
+
+// addOutputs sets the binding outputs of each service in api from outputs.
+// Both are keyed by page name, which catalogAPI and the docs page both derive
+// from the offering name with [slug].
+func addOutputs(api []apiService, outputs map[string][]apiOutput) {
+	for i := range api {
+		api[i].BindingOutputs = outputs[api[i].Name]
+	}
+}
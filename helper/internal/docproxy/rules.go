@@ -0,0 +1,428 @@
+package docproxy
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+//go:embed rules/default.json
+var defaultRules []byte
+
+// Rules rewrite the broker docs page. Each rule matches elements and changes
+// them. Rules are read from JSON:
+//
+//	{"rules": [
+//	  {"name": "title", "match": {"tag": "title"}, "setText": "Services Reference | cloud.gov"},
+//	  {"name": "brand", "match": {"selector": "nav > a.navbar-brand"}, "setText": "Services Reference"}
+//	]}
+//
+// The default rules, in rules/default.json, make the changes cloud.gov needs.
+type Rules struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Rule changes every element that matches Match. Changes are made in the order
+// of the fields below.
+type Rule struct {
+	// Name identifies the rule in errors.
+	Name  string `json:"name"`
+	Match Match  `json:"match"`
+
+	// SetText replaces the element's text, but not its child elements.
+	SetText *string `json:"setText,omitempty"`
+	// TrimText removes leading and trailing characters in the cutset from the
+	// element's text.
+	TrimText *string `json:"trimText,omitempty"`
+	// SetAttrs sets attributes, replacing any existing values.
+	SetAttrs Attrs `json:"setAttrs,omitempty"`
+	// RemoveAttrs removes attributes by name.
+	RemoveAttrs []string `json:"removeAttrs,omitempty"`
+	// Prepend and Append insert nodes as the element's first or last children.
+	Prepend []Node `json:"prepend,omitempty"`
+	Append  []Node `json:"append,omitempty"`
+	// Before and After insert nodes as the element's siblings.
+	Before []Node `json:"before,omitempty"`
+	After  []Node `json:"after,omitempty"`
+	// Remove removes the element and its children.
+	Remove bool `json:"remove,omitempty"`
+}
+
+// Match selects elements. Every field that is set must match.
+type Match struct {
+	// Tag is the element name, like "img".
+	Tag string `json:"tag,omitempty"`
+	// Attrs are attributes the element must have, with exactly these values.
+	Attrs map[string]string `json:"attrs,omitempty"`
+	// Selector is a CSS-like selector: compound selectors of a tag, .class,
+	// #id, [attr] and [attr=value], combined with descendant (space) and child
+	// (>) combinators. Values can't contain spaces.
+	Selector string `json:"selector,omitempty"`
+
+	selector selector
+}
+
+// Node is an element or text node to insert. If Text is set, it is a text node.
+type Node struct {
+	Tag      string `json:"tag,omitempty"`
+	Attrs    Attrs  `json:"attrs,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Children []Node `json:"children,omitempty"`
+}
+
+// Attrs are attributes in the order they are written in JSON, so rendered HTML
+// is deterministic.
+type Attrs []html.Attribute
+
+func (a *Attrs) UnmarshalJSON(b []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return errors.New("attributes must be an object")
+	}
+	for dec.More() {
+		k, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		var v string
+		if err := dec.Decode(&v); err != nil {
+			return fmt.Errorf("attribute %v: %w", k, err)
+		}
+		*a = append(*a, html.Attribute{Key: k.(string), Val: v})
+	}
+	return nil
+}
+
+// DefaultRules returns the built-in rules.
+func DefaultRules() Rules {
+	r, err := ParseRules(defaultRules)
+	if err != nil {
+		panic(fmt.Sprintf("invalid default docproxy rules: %v", err))
+	}
+	return r
+}
+
+// LoadRules reads rules from the JSON file at path. If path is empty, it
+// returns [DefaultRules].
+func LoadRules(path string) (Rules, error) {
+	if path == "" {
+		return DefaultRules(), nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Rules{}, fmt.Errorf("reading docproxy rules: %w", err)
+	}
+	r, err := ParseRules(b)
+	if err != nil {
+		return Rules{}, fmt.Errorf("docproxy rules in %v: %w", path, err)
+	}
+	return r, nil
+}
+
+// ParseRules parses and validates rules from JSON.
+func ParseRules(b []byte) (Rules, error) {
+	var r Rules
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&r); err != nil {
+		return Rules{}, err
+	}
+	var errs []error
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+		if rule.Match.Tag == "" && rule.Match.Selector == "" && len(rule.Match.Attrs) == 0 {
+			errs = append(errs, fmt.Errorf("rule %v (%q): match is empty", i, rule.Name))
+		}
+		if rule.Match.Selector != "" {
+			sel, err := parseSelector(rule.Match.Selector)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("rule %v (%q): %w", i, rule.Name, err))
+			}
+			rule.Match.selector = sel
+		}
+	}
+	return r, errors.Join(errs...)
+}
+
+// Apply changes the document n in place. Each rule is applied in turn to the
+// elements it matches, so later rules see the changes of earlier ones.
+func (r Rules) Apply(n *html.Node) {
+	for _, rule := range r.Rules {
+		var matches []*html.Node
+		walk(n, func(n *html.Node) bool {
+			if rule.Match.matches(n) {
+				matches = append(matches, n)
+			}
+			return false
+		})
+		for _, m := range matches {
+			rule.apply(m)
+		}
+	}
+}
+
+func (m Match) matches(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	if m.Tag != "" && n.Data != m.Tag {
+		return false
+	}
+	for k, v := range m.Attrs {
+		if val, ok := attr(n, k); !ok || val != v {
+			return false
+		}
+	}
+	return m.selector == nil || m.selector.matches(n)
+}
+
+func (r Rule) apply(n *html.Node) {
+	if r.SetText != nil {
+		setText(n, *r.SetText)
+	}
+	if r.TrimText != nil {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.TextNode {
+				c.Data = strings.Trim(c.Data, *r.TrimText)
+			}
+		}
+	}
+	for _, a := range r.SetAttrs {
+		setAttr(n, a.Key, a.Val)
+	}
+	for _, k := range r.RemoveAttrs {
+		n.Attr = deleteAttr(n.Attr, k)
+	}
+	for i := len(r.Prepend) - 1; i >= 0; i-- {
+		n.InsertBefore(r.Prepend[i].build(), n.FirstChild)
+	}
+	for _, c := range r.Append {
+		n.AppendChild(c.build())
+	}
+	if n.Parent != nil {
+		for _, c := range r.Before {
+			n.Parent.InsertBefore(c.build(), n)
+		}
+		for i := len(r.After) - 1; i >= 0; i-- {
+			n.Parent.InsertBefore(r.After[i].build(), n.NextSibling)
+		}
+		if r.Remove {
+			n.Parent.RemoveChild(n)
+		}
+	}
+}
+
+// build returns a new html.Node tree for nd. Nodes are built for each match,
+// since a node can only be in a tree once.
+func (nd Node) build() *html.Node {
+	if nd.Tag == "" {
+		return &html.Node{Type: html.TextNode, Data: nd.Text}
+	}
+	n := &html.Node{Type: html.ElementNode, Data: nd.Tag, Attr: append([]html.Attribute(nil), nd.Attrs...)}
+	if nd.Text != "" {
+		n.AppendChild(&html.Node{Type: html.TextNode, Data: nd.Text})
+	}
+	for _, c := range nd.Children {
+		n.AppendChild(c.build())
+	}
+	return n
+}
+
+// setText replaces the text children of n with text, keeping child elements in
+// place.
+func setText(n *html.Node, text string) {
+	set := false
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.TextNode {
+			if set {
+				n.RemoveChild(c)
+			} else {
+				c.Data = text
+				set = true
+			}
+		}
+		c = next
+	}
+	if !set {
+		n.AppendChild(&html.Node{Type: html.TextNode, Data: text})
+	}
+}
+
+func attr(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+func setAttr(n *html.Node, key string, val string) {
+	for i, a := range n.Attr {
+		if a.Namespace == "" && a.Key == key {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: val})
+}
+
+func deleteAttr(attrs []html.Attribute, key string) []html.Attribute {
+	out := attrs[:0]
+	for _, a := range attrs {
+		if a.Namespace != "" || a.Key != key {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// selector is a parsed Match.Selector, as compound selectors from outermost to
+// innermost.
+type selector []compound
+
+type compound struct {
+	// child is set if the element must be a child, rather than any descendant,
+	// of the element matched by the previous compound.
+	child   bool
+	tag     string
+	id      string
+	classes []string
+	attrs   []attrMatch
+}
+
+type attrMatch struct {
+	key      string
+	val      string
+	hasValue bool
+}
+
+func parseSelector(s string) (selector, error) {
+	var sel selector
+	child := false
+	for _, tok := range strings.Fields(strings.ReplaceAll(s, ">", " > ")) {
+		if tok == ">" {
+			if len(sel) == 0 || child {
+				return nil, fmt.Errorf("selector %q: misplaced '>'", s)
+			}
+			child = true
+			continue
+		}
+		c, err := parseCompound(tok)
+		if err != nil {
+			return nil, fmt.Errorf("selector %q: %w", s, err)
+		}
+		c.child = child
+		child = false
+		sel = append(sel, c)
+	}
+	if len(sel) == 0 || child {
+		return nil, fmt.Errorf("selector %q: incomplete", s)
+	}
+	return sel, nil
+}
+
+func parseCompound(s string) (compound, error) {
+	var c compound
+	i := strings.IndexAny(s, ".#[")
+	if i < 0 {
+		i = len(s)
+	}
+	c.tag, s = s[:i], s[i:]
+	for s != "" {
+		switch s[0] {
+		case '.', '#':
+			end := strings.IndexAny(s[1:], ".#[")
+			if end < 0 {
+				end = len(s) - 1
+			}
+			name := s[1 : end+1]
+			if name == "" {
+				return c, fmt.Errorf("empty class or id")
+			}
+			if s[0] == '.' {
+				c.classes = append(c.classes, name)
+			} else {
+				c.id = name
+			}
+			s = s[end+1:]
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return c, fmt.Errorf("unclosed '['")
+			}
+			k, v, hasValue := strings.Cut(s[1:end], "=")
+			if k == "" {
+				return c, fmt.Errorf("empty attribute name")
+			}
+			c.attrs = append(c.attrs, attrMatch{key: k, val: strings.Trim(v, `"'`), hasValue: hasValue})
+			s = s[end+1:]
+		default:
+			return c, fmt.Errorf("unexpected %q", s)
+		}
+	}
+	return c, nil
+}
+
+func (c compound) matches(n *html.Node) bool {
+	if n == nil || n.Type != html.ElementNode {
+		return false
+	}
+	if c.tag != "" && c.tag != "*" && n.Data != c.tag {
+		return false
+	}
+	if c.id != "" {
+		if id, _ := attr(n, "id"); id != c.id {
+			return false
+		}
+	}
+	if len(c.classes) > 0 {
+		class, _ := attr(n, "class")
+		have := strings.Fields(class)
+		for _, want := range c.classes {
+			found := false
+			for _, h := range have {
+				found = found || h == want
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	for _, a := range c.attrs {
+		val, ok := attr(n, a.key)
+		if !ok || (a.hasValue && val != a.val) {
+			return false
+		}
+	}
+	return true
+}
+
+// matches reports whether n matches the last compound, and its ancestors match
+// the rest.
+func (s selector) matches(n *html.Node) bool {
+	last := len(s) - 1
+	if !s[last].matches(n) {
+		return false
+	}
+	if last == 0 {
+		return true
+	}
+	rest := s[:last]
+	if s[last].child {
+		return rest.matches(n.Parent)
+	}
+	for p := n.Parent; p != nil; p = p.Parent {
+		if rest.matches(p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package docproxy_test
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+
+	"github.com/cloud-gov/csb/helper/internal/docproxy"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata with the current output")
+
+// testManifest mirrors assets/manifest.json.
+var testManifest = docproxy.Manifest{"https://services.cloud.gov/images/amazon-ses.svg": "images/amazon-ses.svg"}
+
+// apply parses doc, applies rules, rewrites assets with manifest and renders
+// the result.
+func apply(t *testing.T, rules docproxy.Rules, manifest docproxy.Manifest, doc string) string {
+	t.Helper()
+	n, err := html.Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules.Apply(n)
+	manifest.Rewrite(n)
+	var buf bytes.Buffer
+	if err := html.Render(&buf, n); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// TestDefaultRulesGolden checks the default rules against
+// testdata/*.golden.html. Run with -update after an intended change.
+func TestDefaultRulesGolden(t *testing.T) {
+	inputs, err := filepath.Glob("testdata/*.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, in := range inputs {
+		if strings.HasSuffix(in, ".golden.html") {
+			continue
+		}
+		t.Run(filepath.Base(in), func(t *testing.T) {
+			b, err := os.ReadFile(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := apply(t, docproxy.DefaultRules(), testManifest, string(b))
+
+			golden := strings.TrimSuffix(in, ".html") + ".golden.html"
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("output differs from %v. Run go test -update to accept it.\ngot:\n%v", golden, got)
+			}
+		})
+	}
+}
+
+func TestParseRulesErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		json string
+		want string
+	}{
+		{name: "unknown action", json: `{"rules": [{"match": {"tag": "a"}, "setHTML": "x"}]}`, want: "unknown field"},
+		{name: "empty match", json: `{"rules": [{"name": "oops", "match": {}, "remove": true}]}`, want: "match is empty"},
+		{name: "bad selector", json: `{"rules": [{"match": {"selector": "div > > a"}, "remove": true}]}`, want: "misplaced"},
+		{name: "unclosed attribute", json: `{"rules": [{"match": {"selector": "a[href"}, "remove": true}]}`, want: "unclosed"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := docproxy.ParseRules([]byte(tc.json))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("expected error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestRules(t *testing.T) {
+	const doc = `<html><head></head><body><div id="main"><p class="lead intro">Hi <b>there</b> you</p><a href="/x" target="_blank">x</a></div><p>Other</p></body></html>`
+	cases := []struct {
+		name  string
+		rules string
+		want  string
+	}{
+		{
+			name:  "child selector with class",
+			rules: `{"rules": [{"match": {"selector": "div#main > p.intro"}, "setText": "Hello"}]}`,
+			want:  `<div id="main"><p class="lead intro">Hello<b>there</b></p>`,
+		},
+		{
+			name:  "descendant selector with attribute",
+			rules: `{"rules": [{"match": {"selector": "body a[target=_blank]"}, "setAttrs": {"rel": "noopener", "href": "/y"}, "removeAttrs": ["target"]}]}`,
+			want:  `<a href="/y" rel="noopener">x</a>`,
+		},
+		{
+			name:  "remove",
+			rules: `{"rules": [{"match": {"selector": "div p"}, "remove": true}]}`,
+			want:  `<div id="main"><a href="/x" target="_blank">x</a></div><p>Other</p>`,
+		},
+		{
+			name:  "insert around and inside",
+			rules: `{"rules": [{"match": {"tag": "a"}, "before": [{"tag": "hr"}], "after": [{"text": "!"}], "prepend": [{"tag": "i", "text": "go "}]}]}`,
+			want:  `<hr/><a href="/x" target="_blank"><i>go </i>x</a>!`,
+		},
+		{
+			name:  "attribute match is exact",
+			rules: `{"rules": [{"match": {"attrs": {"class": "lead"}}, "remove": true}]}`,
+			want:  `<p class="lead intro">`,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rules, err := docproxy.ParseRules([]byte(tc.rules))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := apply(t, rules, nil, doc); !strings.Contains(got, tc.want) {
+				t.Errorf("expected output to contain %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
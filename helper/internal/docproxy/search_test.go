@@ -0,0 +1,99 @@
+package docproxy_test
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cloud-gov/csb/helper/internal/config"
+	"github.com/cloud-gov/csb/helper/internal/docproxy"
+)
+
+func TestSearch(t *testing.T) {
+	var version atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if version.Load() == 0 {
+			http.ServeFile(w, r, "testdata/docs.html")
+			return
+		}
+		io.WriteString(w, `<html><head><title>CSB</title></head><body><div><h1><code>aws-rds</code></h1><p>Managed databases.</p></div></body></html>`)
+	}))
+	defer upstream.Close()
+	u, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := docproxy.NewDocs(slog.New(slog.NewTextHandler(io.Discard, nil)), config.Config{BrokerURL: *u, DocsCacheTTL: time.Millisecond}, docproxy.DefaultRules(), testManifest)
+	h := docs.HandleSearch()
+
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{query: "domain", want: []string{`<a href="services/aws-ses">domain</a>`, "<mark>Domain</mark> from which mail will be sent."}},
+		{query: "binding s3", want: []string{`href="services/aws-ses#aws-ses-binding"`}},
+		{query: "nothing here", want: []string{"No results for <strong>nothing here</strong>."}},
+		{query: "", want: []string{"Search the services reference"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.query, func(t *testing.T) {
+			rec := get(h, "/search?q="+url.QueryEscape(tc.query))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %v, got %v", http.StatusOK, rec.Code)
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(rec.Body.String(), want) {
+					t.Errorf("expected results to contain %v, got %v", want, rec.Body.String())
+				}
+			}
+		})
+	}
+
+	t.Run("index is rebuilt when the docs change", func(t *testing.T) {
+		version.Store(1)
+		time.Sleep(2 * time.Millisecond)
+		get(h, "/search?q=x") // Starts the refresh.
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			if strings.Contains(get(h, "/search?q=databases").Body.String(), `href="services/aws-rds`) {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Error("expected the new docs to be searchable")
+	})
+}
+
+func TestSearchSkipsChrome(t *testing.T) {
+	// Without an h1, the whole page is indexed as one entry, which must not
+	// include the banner, header or footer.
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `<html><head><title>CSB</title></head><body><p>Managed databases.</p></body></html>`)
+	}))
+	defer upstream.Close()
+	u, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := docproxy.NewDocs(slog.New(slog.NewTextHandler(io.Discard, nil)), config.Config{BrokerURL: *u, DocsCacheTTL: time.Hour}, docproxy.DefaultRules(), testManifest)
+	h := docs.HandleSearch()
+
+	for query, want := range map[string]string{
+		"databases":        `<mark>databases</mark>`,
+		"official website": "No results for <strong>official website</strong>.",
+		"General Services": "No results for <strong>General Services</strong>.",
+	} {
+		t.Run(query, func(t *testing.T) {
+			rec := get(h, "/search?q="+url.QueryEscape(query))
+			if !strings.Contains(rec.Body.String(), want) {
+				t.Errorf("expected results to contain %v, got %v", want, rec.Body.String())
+			}
+		})
+	}
+}
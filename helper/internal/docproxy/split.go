@@ -0,0 +1,381 @@
+package docproxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// indexPage is the key of the index page in the pages returned by
+// splitServices.
+const indexPage = ""
+
+// service is a section of the broker docs about one service offering.
+type service struct {
+	Name    string
+	Title   string
+	Summary string
+}
+
+// section is the range of nodes, among the children of the content element,
+// about one service.
+type section struct {
+	service
+	first, last *html.Node
+}
+
+// splitServices splits the rendered docs page into an index page and a page for
+// each service offering, keyed by offering name, made unique by [uniqueNames].
+// Relative URLs on every page resolve from basePath; see [addBase]. The broker
+// renders each offering as an h1 followed by its description, plans and
+// parameters, with the h1s as siblings in one content element. If the page has
+// no h1, it is returned as the index page only.
+//
+// Links to anchors are rewritten to the page that now has the anchor. The index
+// page also embeds a map from anchors to pages, which assets/redirect.js uses
+// to redirect bookmarked links to the old single page, like /#service-aws-ses.
+func splitServices(rendered []byte, basePath string) (map[string][]byte, []service, error) {
+	doc, container, sections, err := parseSections(rendered)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(sections) == 0 {
+		return map[string][]byte{indexPage: rendered}, nil, nil
+	}
+
+	anchors := map[string]string{}
+	services := make([]service, len(sections))
+	for i, s := range sections {
+		services[i] = s.service
+		for n := s.first; n != s.last.NextSibling; n = n.NextSibling {
+			walk(n, func(n *html.Node) bool {
+				if id, ok := attr(n, "id"); ok && n.Type == html.ElementNode {
+					anchors[id] = servicePath(s.Name)
+				}
+				return false
+			})
+		}
+	}
+
+	pages := make(map[string][]byte, len(sections)+1)
+	// Each page is cut from a fresh parse, since cutting changes the tree.
+	for i := -1; i < len(sections); i++ {
+		if i >= 0 {
+			doc, container, sections, err = parseSections(rendered)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		var keep func(*html.Node) bool
+		name := indexPage
+		if i < 0 {
+			// The index keeps the introduction before the first service.
+			first := sections[0].first
+			keep = func(n *html.Node) bool { return precedes(n, first) }
+		} else {
+			s := sections[i]
+			name = s.Name
+			keep = func(n *html.Node) bool { return within(n, s.first, s.last) }
+		}
+		for c := container.FirstChild; c != nil; {
+			next := c.NextSibling
+			if !keep(c) {
+				container.RemoveChild(c)
+			}
+			c = next
+		}
+
+		rewriteAnchors(doc, anchors)
+		addBase(doc, basePath)
+		if i < 0 {
+			container.AppendChild(serviceIndex(services))
+			if err := addAnchorRedirect(doc, anchors); err != nil {
+				return nil, nil, err
+			}
+		} else {
+			prefixTitle(doc, sections[i].Name)
+		}
+
+		var buf bytes.Buffer
+		if err := html.Render(&buf, doc); err != nil {
+			return nil, nil, fmt.Errorf("rendering page for %q: %w", name, err)
+		}
+		pages[name] = buf.Bytes()
+	}
+	return pages, services, nil
+}
+
+// parseSections parses the docs page and finds the content element and the
+// service sections in it.
+func parseSections(rendered []byte) (doc *html.Node, container *html.Node, sections []section, err error) {
+	doc, err = html.Parse(bytes.NewReader(rendered))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	container, sections = findSections(doc)
+	return doc, container, sections, nil
+}
+
+// findSections finds the content element of doc and the service sections in it.
+// container is nil if doc has no h1.
+func findSections(doc *html.Node) (container *html.Node, sections []section) {
+	walk(doc, func(n *html.Node) bool {
+		if n.Type == html.ElementNode && n.DataAtom == atom.H1 {
+			container = n.Parent
+			return true
+		}
+		return false
+	})
+	if container == nil {
+		return nil, nil
+	}
+
+	for c := container.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode || c.DataAtom != atom.H1 {
+			if len(sections) > 0 {
+				sections[len(sections)-1].last = c
+			}
+			continue
+		}
+		if len(sections) > 0 {
+			trimSeparators(&sections[len(sections)-1])
+		}
+		sections = append(sections, section{service: describe(c), first: c, last: c})
+	}
+	trimSeparators(&sections[len(sections)-1])
+	names := make([]string, len(sections))
+	for i, s := range sections {
+		names[i] = s.Name
+	}
+	uniqueNames(names)
+	for i := range sections {
+		sections[i].Name = names[i]
+	}
+	return container, sections
+}
+
+// uniqueNames renames the services named by names, in order, whose pages would
+// replace another page: a service with no name, which would replace the index
+// page, is named "service", and a repeated name gets a numeric suffix, like
+// "aws-ses-2".
+func uniqueNames(names []string) {
+	taken := map[string]bool{}
+	for i, base := range names {
+		if base == indexPage {
+			base = "service"
+		}
+		name := base
+		for n := 2; taken[name]; n++ {
+			name = fmt.Sprintf("%v-%v", base, n)
+		}
+		taken[name] = true
+		names[i] = name
+	}
+}
+
+// trimSeparators moves the end of s back past the whitespace and hr elements
+// the broker puts between services.
+func trimSeparators(s *section) {
+	for s.last != s.first {
+		isSpace := s.last.Type == html.TextNode && strings.TrimSpace(s.last.Data) == ""
+		isRule := s.last.Type == html.ElementNode && s.last.DataAtom == atom.Hr
+		if !isSpace && !isRule {
+			return
+		}
+		s.last = s.last.PrevSibling
+	}
+}
+
+var nonSlug = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// describe names the service whose section starts with the heading h. The
+// offering name is the heading's code element, or else its text.
+func describe(h *html.Node) service {
+	s := service{Title: strings.TrimSpace(text(h))}
+	walk(h, func(n *html.Node) bool {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Code {
+			s.Name = strings.TrimSpace(text(n))
+			return true
+		}
+		return false
+	})
+	if s.Name == "" {
+		s.Name = s.Title
+	}
+	s.Name = slug(s.Name)
+	for p := h.NextSibling; p != nil; p = p.NextSibling {
+		if p.Type == html.ElementNode && p.DataAtom == atom.P {
+			s.Summary = strings.TrimSpace(text(p))
+			break
+		}
+		if p.Type == html.ElementNode && p.DataAtom == atom.H1 {
+			break
+		}
+	}
+	return s
+}
+
+// slug turns an offering name into the name of its page, of lowercase letters,
+// digits and dashes.
+func slug(name string) string {
+	return strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(name), "-"), "-")
+}
+
+func text(n *html.Node) string {
+	var b strings.Builder
+	walk(n, func(n *html.Node) bool {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		return false
+	})
+	return b.String()
+}
+
+func servicePath(name string) string {
+	return "services/" + name
+}
+
+// precedes reports whether n is a sibling before first.
+func precedes(n, first *html.Node) bool {
+	if n == first {
+		return false
+	}
+	for c := n.NextSibling; c != nil; c = c.NextSibling {
+		if c == first {
+			return true
+		}
+	}
+	return false
+}
+
+// within reports whether n is one of the siblings from first to last.
+func within(n, first, last *html.Node) bool {
+	for c := first; c != nil; c = c.NextSibling {
+		if c == n {
+			return true
+		}
+		if c == last {
+			return false
+		}
+	}
+	return false
+}
+
+// rewriteAnchors points links to anchors that moved to a service page at that
+// page. The links are relative to the base URL set by addBase.
+func rewriteAnchors(doc *html.Node, anchors map[string]string) {
+	walk(doc, func(n *html.Node) bool {
+		if n.Type != html.ElementNode || n.DataAtom != atom.A {
+			return false
+		}
+		href, _ := attr(n, "href")
+		id, ok := strings.CutPrefix(href, "#")
+		if !ok {
+			return false
+		}
+		if page, ok := anchors[id]; ok {
+			setAttr(n, "href", page+href)
+		}
+		return false
+	})
+}
+
+// addBase makes relative URLs resolve from basePath, the root of the helper's
+// pages, so the same links and asset paths work on the index and on service
+// pages, wherever the helper is published.
+func addBase(doc *html.Node, basePath string) {
+	walk(doc, func(n *html.Node) bool {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Head {
+			n.InsertBefore(&html.Node{Type: html.ElementNode, Data: "base", DataAtom: atom.Base, Attr: []html.Attribute{{Key: "href", Val: basePath}}}, n.FirstChild)
+			return true
+		}
+		return false
+	})
+}
+
+// urlAttrs are the attributes that hold URLs, by element.
+var urlAttrs = map[atom.Atom]string{
+	atom.A:      "href",
+	atom.Link:   "href",
+	atom.Img:    "src",
+	atom.Script: "src",
+	atom.Form:   "action",
+}
+
+// resolveURLs makes the relative URLs in doc absolute, resolved against base,
+// the URL doc was fetched from, so the base URL set by addBase doesn't change
+// where they point. Links to anchors in doc are left alone.
+func resolveURLs(doc *html.Node, base *url.URL) {
+	walk(doc, func(n *html.Node) bool {
+		key, ok := urlAttrs[n.DataAtom]
+		if n.Type != html.ElementNode || !ok {
+			return false
+		}
+		v, ok := attr(n, key)
+		if !ok || strings.HasPrefix(v, "#") {
+			return false
+		}
+		ref, err := url.Parse(strings.TrimSpace(v))
+		if err != nil || ref.IsAbs() {
+			return false
+		}
+		setAttr(n, key, base.ResolveReference(ref).String())
+		return false
+	})
+}
+
+// serviceIndex builds the list of links to service pages.
+func serviceIndex(services []service) *html.Node {
+	ul := &html.Node{Type: html.ElementNode, Data: "ul", DataAtom: atom.Ul, Attr: []html.Attribute{{Key: "class", Val: "cg-service-index"}}}
+	for _, s := range services {
+		li := &html.Node{Type: html.ElementNode, Data: "li", DataAtom: atom.Li}
+		a := &html.Node{Type: html.ElementNode, Data: "a", DataAtom: atom.A, Attr: []html.Attribute{{Key: "href", Val: servicePath(s.Name)}}}
+		a.AppendChild(&html.Node{Type: html.TextNode, Data: s.Name})
+		li.AppendChild(a)
+		if s.Summary != "" {
+			li.AppendChild(&html.Node{Type: html.TextNode, Data: ": " + s.Summary})
+		}
+		ul.AppendChild(li)
+	}
+	return ul
+}
+
+// addAnchorRedirect embeds the map from anchors to pages and the script that
+// follows it.
+func addAnchorRedirect(doc *html.Node, anchors map[string]string) error {
+	b, err := json.Marshal(anchors)
+	if err != nil {
+		return err
+	}
+	walk(doc, func(n *html.Node) bool {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Body {
+			data := &html.Node{Type: html.ElementNode, Data: "script", DataAtom: atom.Script, Attr: []html.Attribute{{Key: "type", Val: "application/json"}, {Key: "id", Val: "cg-anchor-map"}}}
+			// json.Marshal escapes <, > and &, so the map can't close the
+			// script element.
+			data.AppendChild(&html.Node{Type: html.RawNode, Data: string(b)})
+			n.AppendChild(data)
+			n.AppendChild(&html.Node{Type: html.ElementNode, Data: "script", DataAtom: atom.Script, Attr: []html.Attribute{{Key: "src", Val: "assets/redirect.js"}}})
+			return true
+		}
+		return false
+	})
+	return nil
+}
+
+// prefixTitle adds the service name to the page title.
+func prefixTitle(doc *html.Node, name string) {
+	walk(doc, func(n *html.Node) bool {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Title {
+			setText(n, name+" | "+strings.TrimSpace(text(n)))
+			return true
+		}
+		return false
+	})
+}
@@ -0,0 +1,98 @@
+package docproxy_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestServicePagesGolden checks the index and service pages split from
+// testdata/docs.html against testdata/pages/*.golden.html. Run with -update
+// after an intended change.
+func TestServicePagesGolden(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "testdata/docs.html")
+	}))
+	defer upstream.Close()
+	docs := handleDocs(t, upstream, time.Hour)
+	mux := http.NewServeMux()
+	mux.Handle("/", docs)
+	mux.Handle("GET /services/{name}", docs)
+
+	for path, golden := range map[string]string{
+		"/":                 "index",
+		"/services/aws-ses": "aws-ses",
+		"/services/aws-s3":  "aws-s3",
+	} {
+		t.Run(golden, func(t *testing.T) {
+			rec := get(mux, path)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %v, got %v", http.StatusOK, rec.Code)
+			}
+			got := rec.Body.String()
+
+			file := filepath.Join("testdata", "pages", golden+".golden.html")
+			if *update {
+				os.MkdirAll(filepath.Dir(file), 0o755)
+				if err := os.WriteFile(file, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("output differs from %v. Run go test -update to accept it.\ngot:\n%v", file, got)
+			}
+		})
+	}
+
+	t.Run("unknown service", func(t *testing.T) {
+		rec := get(mux, "/services/aws-nope")
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("expected status %v, got %v", http.StatusNotFound, rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), "aws-nope") {
+			t.Error("expected the not found page to name the service")
+		}
+	})
+}
+
+func TestServicePagesNameCollisions(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `<!DOCTYPE html><html><head><title>Docs</title></head><body><div>
+<p>Introduction</p>
+<h1>Service <code>aws-ses</code></h1><p>First SES</p>
+<h1>Service <code>aws-ses</code></h1><p>Second SES</p>
+<h1>!!!</h1><p>No name</p>
+</div></body></html>`)
+	}))
+	defer upstream.Close()
+	docs := handleDocs(t, upstream, time.Hour)
+	mux := http.NewServeMux()
+	mux.Handle("/", docs)
+	mux.Handle("GET /services/{name}", docs)
+
+	for path, want := range map[string]string{
+		"/":                   "Introduction",
+		"/services/aws-ses":   "First SES",
+		"/services/aws-ses-2": "Second SES",
+		"/services/service":   "No name",
+	} {
+		t.Run(path, func(t *testing.T) {
+			rec := get(mux, path)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status %v, got %v", http.StatusOK, rec.Code)
+			}
+			if !strings.Contains(rec.Body.String(), want) {
+				t.Errorf("expected page to contain %q, got:\n%v", want, rec.Body.String())
+			}
+		})
+	}
+}
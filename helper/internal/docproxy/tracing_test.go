@@ -0,0 +1,50 @@
+package docproxy_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// spans records every span ended during the tests. The global tracer provider
+// can only be delegated to once, so it is installed for the whole package in
+// TestMain.
+var spans = tracetest.NewInMemoryExporter()
+
+func TestMain(m *testing.M) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans)))
+	os.Exit(m.Run())
+}
+
+func TestSpanNames(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "testdata/docs.html")
+	}))
+	defer upstream.Close()
+	docs := handleDocs(t, upstream, time.Hour)
+	mux := http.NewServeMux()
+	mux.Handle("/", docs)
+	mux.Handle("GET /services/{name}", docs)
+
+	for path, want := range map[string]string{
+		"/":                 "GET /",
+		"/services/aws-ses": "GET /services/{name}",
+	} {
+		spans.Reset()
+		get(mux, path)
+		var names []string
+		for _, s := range spans.GetSpans() {
+			names = append(names, s.Name)
+		}
+		if !slices.Contains(names, want) {
+			t.Errorf("%v: expected span %q, got spans %v", path, want, names)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Package health serves liveness and readiness endpoints that report on the
+// helper's dependencies.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Check reports whether a dependency is usable. A nil error means healthy.
+type Check func(ctx context.Context) error
+
+// CheckResult is the outcome of a single [Check].
+type CheckResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Response is the JSON body returned by the health endpoints.
+type Response struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// HandleLive reports that the process is up and serving requests. It
+// deliberately checks no dependencies, so a broken dependency doesn't cause
+// Cloud Foundry to restart the app.
+func HandleLive() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, Response{Status: StatusOK})
+	})
+}
+
+// HandleReady runs every check concurrently, each limited to timeout, and
+// reports whether each passed. It responds 200 if all checks pass and 503
+// otherwise. The route is public, so the errors of failed checks are only
+// logged, not returned.
+func HandleReady(logger *slog.Logger, checks map[string]Check, timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := Run(r.Context(), checks, timeout)
+		code := http.StatusOK
+		if resp.Status != StatusOK {
+			code = http.StatusServiceUnavailable
+			logger.WarnContext(r.Context(), "readiness check failed", "checks", resp.Checks)
+		}
+		for name, res := range resp.Checks {
+			res.Error = ""
+			resp.Checks[name] = res
+		}
+		writeJSON(w, code, resp)
+	})
+}
+
+// Run runs every check concurrently, each limited to timeout, and collects the
+// results.
+func Run(ctx context.Context, checks map[string]Check, timeout time.Duration) Response {
+	resp := Response{Status: StatusOK, Checks: make(map[string]CheckResult, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			res := CheckResult{Status: StatusOK}
+			if err := check(ctx); err != nil {
+				res = CheckResult{Status: StatusFail, Error: err.Error()}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			resp.Checks[name] = res
+			if res.Status != StatusOK {
+				resp.Status = StatusFail
+			}
+		}()
+	}
+	wg.Wait()
+	return resp
+}
+
+// HTTPReachable returns a Check that passes if a GET request to url succeeds
+// with a non-5xx status.
+func HTTPReachable(client *http.Client, url string) Check {
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			return fmt.Errorf("%v responded with status %v", url, resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// Static returns a Check that always returns err. Use it to report the outcome
+// of a step that only runs at startup.
+func Static(err error) Check {
+	return func(context.Context) error {
+		return err
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
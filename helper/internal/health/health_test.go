@@ -0,0 +1,107 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cloud-gov/csb/helper/internal/health"
+)
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestHandleLive(t *testing.T) {
+	rec := httptest.NewRecorder()
+	health.HandleLive().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %v, got %v", http.StatusOK, rec.Code)
+	}
+}
+
+func TestHTTPReachable(t *testing.T) {
+	t.Run("passes on 2xx", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer ts.Close()
+		if err := health.HTTPReachable(ts.Client(), ts.URL)(context.Background()); err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+	})
+
+	t.Run("fails on 5xx", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer ts.Close()
+		if err := health.HTTPReachable(ts.Client(), ts.URL)(context.Background()); err == nil {
+			t.Fatal("expected non-nil error")
+		}
+	})
+
+	t.Run("fails when unreachable", func(t *testing.T) {
+		if err := health.HTTPReachable(http.DefaultClient, "http://127.0.0.1:9999")(context.Background()); err == nil {
+			t.Fatal("expected non-nil error")
+		}
+	})
+}
+
+func TestHandleReady(t *testing.T) {
+	ok := func(context.Context) error { return nil }
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	cases := []struct {
+		Name   string
+		Checks map[string]health.Check
+		Code   int
+		Want   map[string]string
+	}{
+		{
+			Name:   "all checks pass",
+			Checks: map[string]health.Check{"a": ok, "b": ok},
+			Code:   http.StatusOK,
+			Want:   map[string]string{"a": health.StatusOK, "b": health.StatusOK},
+		},
+		{
+			Name:   "one check fails",
+			Checks: map[string]health.Check{"a": ok, "b": health.Static(errors.New("boom"))},
+			Code:   http.StatusServiceUnavailable,
+			Want:   map[string]string{"a": health.StatusOK, "b": health.StatusFail},
+		},
+		{
+			Name:   "check exceeds timeout",
+			Checks: map[string]health.Check{"slow": slow},
+			Code:   http.StatusServiceUnavailable,
+			Want:   map[string]string{"slow": health.StatusFail},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			health.HandleReady(discard, tc.Checks, 50*time.Millisecond).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if rec.Code != tc.Code {
+				t.Fatalf("expected status %v, got %v", tc.Code, rec.Code)
+			}
+			var resp health.Response
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("unmarshalling response: %v", err)
+			}
+			for name, want := range tc.Want {
+				if got := resp.Checks[name].Status; got != want {
+					t.Errorf("check %q: expected status %q, got %q", name, want, got)
+				}
+				if e := resp.Checks[name].Error; e != "" {
+					t.Errorf("check %q: expected no error details in the public response, got %q", name, e)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+// Package metrics defines the Prometheus collectors exposed by the CSB Helper.
+//
+// Collectors are registered on [Registry] rather than the Prometheus default
+// registry so the helper only exposes metrics it owns, plus the standard Go and
+// process collectors.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "csb_helper"
+
+// Registry holds every collector the helper exposes.
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+var (
+	// SNSMessages counts verified SNS messages by SNS message type.
+	SNSMessages = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sns_messages_total",
+		Help:      "Verified SNS messages received, by SNS message type.",
+	}, []string{"type"})
+
+	// SNSVerificationFailures counts SNS messages that failed verification, by
+	// the ErrSNS* sentinel that caused the failure.
+	SNSVerificationFailures = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sns_verification_failures_total",
+		Help:      "SNS messages that failed signature or topic verification, by reason.",
+	}, []string{"reason"})
+
+	// SESPauses counts SES configuration sets paused in response to an alarm,
+	// by alarm class.
+	SESPauses = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ses_pauses_total",
+		Help:      "SES configuration sets paused in response to a CloudWatch alarm, by alarm class.",
+	}, []string{"alarm_class"})
+
+	// SESAPIErrors counts failed calls to the SES API, by operation.
+	SESAPIErrors = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ses_api_errors_total",
+		Help:      "Failed calls to the SES API, by operation.",
+	}, []string{"operation"})
+
+	// DocproxyUpstreamDuration observes how long requests to the upstream
+	// broker docs take, by response status.
+	DocproxyUpstreamDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "docproxy_upstream_request_duration_seconds",
+		Help:      "Latency of requests to the upstream broker docs page, by HTTP status code or \"error\".",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"status"})
+
+	// AssetErrors counts failures to serve embedded assets, by reason.
+	AssetErrors = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "asset_errors_total",
+		Help:      "Failures serving embedded assets, by reason.",
+	}, []string{"reason"})
+
+	// RateLimited counts requests rejected by the rate limiter, by route group.
+	RateLimited = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_requests_total",
+		Help:      "Requests rejected with 429 by the per-client rate limiter, by route group.",
+	}, []string{"group"})
+
+	// Panics counts panics recovered from HTTP handlers.
+	Panics = factory.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "panics_total",
+		Help:      "Panics recovered from HTTP handlers.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Handler serves the collectors in [Registry] in the Prometheus exposition
+// format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
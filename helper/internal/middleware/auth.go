@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/cloud-gov/csb/helper/internal/auth"
+	"github.com/cloud-gov/csb/helper/internal/logging"
+)
+
+// Authenticate requires a UAA bearer token, verified by v, with every one of
+// scopes. The token's claims are added to the request context; see
+// [auth.ClaimsFromContext]. It responds with JSON 401 if the token is missing
+// or invalid, 403 if it lacks a scope, and 503 if the signing keys can't be
+// fetched, with a WWW-Authenticate header as in RFC 6750.
+func Authenticate(h http.Handler, logger *slog.Logger, v *auth.Verifier, scopes ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Auth schemes are case-insensitive (RFC 9110, section 11.1).
+		scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !strings.EqualFold(scheme, "Bearer") || token == "" {
+			unauthorized(w, r, http.StatusUnauthorized, `Bearer`, "missing bearer token")
+			return
+		}
+
+		claims, err := v.Verify(r.Context(), token)
+		if err != nil && !auth.IsTokenError(err) {
+			logger.ErrorContext(r.Context(), "verifying bearer token", "path", r.URL.Path, "err", err)
+			unauthorized(w, r, http.StatusServiceUnavailable, `Bearer`, "could not verify bearer token")
+			return
+		}
+		if err != nil {
+			logger.WarnContext(r.Context(), "rejected bearer token", "path", r.URL.Path, "err", err)
+			unauthorized(w, r, http.StatusUnauthorized, `Bearer error="invalid_token"`, "invalid bearer token")
+			return
+		}
+		if !claims.HasScopes(scopes...) {
+			err := fmt.Errorf("%w: want %v", auth.ErrInsufficientScopes, scopes)
+			logger.WarnContext(r.Context(), "rejected bearer token", "path", r.URL.Path, "actor", claims.Actor(), "err", err)
+			unauthorized(w, r, http.StatusForbidden, fmt.Sprintf(`Bearer error="insufficient_scope", scope="%v"`, strings.Join(scopes, " ")), "insufficient scope")
+			return
+		}
+
+		h.ServeHTTP(w, r.WithContext(auth.WithClaims(r.Context(), claims)))
+	})
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request, code int, challenge string, msg string) {
+	w.Header().Set("WWW-Authenticate", challenge)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":      msg,
+		"request_id": logging.RequestID(r.Context()),
+	})
+}
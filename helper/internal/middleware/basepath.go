@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/cloud-gov/csb/helper/internal/pages"
+)
+
+type ctxKey int
+
+const (
+	basePathKey ctxKey = iota
+	navKey
+)
+
+// StripBasePath serves requests for paths under base, like
+// /docs/services/aws-ses, as if they were for the path without it, like
+// /services/aws-ses, for when a proxy in front of the helper routes base to it
+// without removing the prefix. base itself without its trailing slash is
+// redirected to base. Other paths are served as they are, so the helper still
+// works when it is reached directly. base is stored in the request context for
+// [BasePath], so pages can link under it.
+func StripBasePath(h http.Handler, base string) http.Handler {
+	prefix := strings.TrimSuffix(base, "/")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(context.WithValue(r.Context(), basePathKey, base))
+		if prefix == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if r.URL.Path == prefix {
+			u := *r.URL
+			u.Path, u.RawPath = base, ""
+			http.Redirect(w, r, u.RequestURI(), http.StatusMovedPermanently)
+			return
+		}
+		rest, ok := strings.CutPrefix(r.URL.Path, base)
+		if !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		u := *r.URL
+		u.Path = "/" + rest
+		u.RawPath = ""
+		if raw, ok := strings.CutPrefix(r.URL.RawPath, base); ok {
+			u.RawPath = "/" + raw
+		}
+		r2 := *r
+		r2.URL = &u
+		h.ServeHTTP(w, &r2)
+	})
+}
+
+// BasePath returns the path the helper's pages are published under, as set by
+// [StripBasePath], or "/" if it isn't set.
+func BasePath(ctx context.Context) string {
+	if base, ok := ctx.Value(basePathKey).(string); ok {
+		return base
+	}
+	return "/"
+}
+
+// NavLinks stores nav in the request context, for the cloud.gov header of the
+// error pages that middleware renders.
+func NavLinks(h http.Handler, nav []pages.Link) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), navKey, nav)))
+	})
+}
+
+// site returns the base path and nav links stored in ctx by [StripBasePath] and
+// [NavLinks].
+func site(ctx context.Context) pages.Site {
+	nav, _ := ctx.Value(navKey).([]pages.Link)
+	return pages.Site{BasePath: BasePath(ctx), Nav: nav}
+}
@@ -0,0 +1,61 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cloud-gov/csb/helper/internal/middleware"
+)
+
+func TestStripBasePath(t *testing.T) {
+	var gotPath, gotBase string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotBase = middleware.BasePath(r.Context())
+	})
+
+	tests := []struct {
+		name     string
+		base     string
+		target   string
+		wantPath string
+		wantLoc  string
+	}{
+		{name: "root base", base: "/", target: "/services/aws-ses", wantPath: "/services/aws-ses"},
+		{name: "under base", base: "/docs/", target: "/docs/services/aws-ses", wantPath: "/services/aws-ses"},
+		{name: "base itself", base: "/docs/", target: "/docs/", wantPath: "/"},
+		{name: "base without slash", base: "/docs/", target: "/docs?q=ses", wantLoc: "/docs/?q=ses"},
+		{name: "outside base", base: "/docs/", target: "/healthz", wantPath: "/healthz"},
+		{name: "prefix of a longer segment", base: "/docs/", target: "/docsearch", wantPath: "/docsearch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotPath, gotBase = "", ""
+			rec := httptest.NewRecorder()
+			middleware.StripBasePath(next, tt.base).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if tt.wantLoc != "" {
+				if rec.Code != http.StatusMovedPermanently {
+					t.Fatalf("expected status %v, got %v", http.StatusMovedPermanently, rec.Code)
+				}
+				if loc := rec.Header().Get("Location"); loc != tt.wantLoc {
+					t.Errorf("expected Location %q, got %q", tt.wantLoc, loc)
+				}
+				return
+			}
+			if gotPath != tt.wantPath {
+				t.Errorf("expected path %q, got %q", tt.wantPath, gotPath)
+			}
+			if gotBase != tt.base {
+				t.Errorf("expected base path %q, got %q", tt.base, gotBase)
+			}
+		})
+	}
+}
+
+func TestBasePathDefault(t *testing.T) {
+	if got := middleware.BasePath(httptest.NewRequest(http.MethodGet, "/", nil).Context()); got != "/" {
+		t.Errorf("expected %q, got %q", "/", got)
+	}
+}
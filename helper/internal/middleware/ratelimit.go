@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"encoding/json"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/cloud-gov/csb/helper/internal/logging"
+	"github.com/cloud-gov/csb/helper/internal/metrics"
+	"github.com/cloud-gov/csb/helper/internal/pages"
+	"github.com/cloud-gov/csb/helper/internal/ratelimit"
+)
+
+// RateGroup limits requests whose path starts with Prefix. A nil Limiter means
+// the group is unlimited.
+type RateGroup struct {
+	Name    string
+	Prefix  string
+	Limiter *ratelimit.Limiter
+}
+
+// RateLimit limits each client, identified by [ClientIP], with the limiter of
+// the first group whose prefix matches the request path. Requests matching no
+// group are not limited. Limited requests get 429 with Retry-After: with JSON
+// if the path starts with one of apiPrefixes, and with the branded HTML error
+// page otherwise.
+func RateLimit(h http.Handler, logger *slog.Logger, trustedHops int, groups []RateGroup, apiPrefixes ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var group RateGroup
+		for _, g := range groups {
+			if strings.HasPrefix(r.URL.Path, g.Prefix) {
+				group = g
+				break
+			}
+		}
+		if group.Limiter == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		client := ClientIP(r, trustedHops)
+		ok, wait := group.Limiter.Allow(client)
+		if ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		metrics.RateLimited.WithLabelValues(group.Name).Inc()
+		logger.WarnContext(r.Context(), "rate limited request", "group", group.Name, "client", client, "path", r.URL.Path)
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		if isAPI(r, apiPrefixes) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error":      http.StatusText(http.StatusTooManyRequests),
+				"request_id": logging.RequestID(r.Context()),
+			})
+			return
+		}
+		err := pages.RenderError(w, http.StatusTooManyRequests, pages.Error{
+			Site:      site(r.Context()),
+			Title:     "Too many requests",
+			Message:   "You have made too many requests to this page. Wait a minute and try again.",
+			RequestID: logging.RequestID(r.Context()),
+		})
+		if err != nil {
+			logger.ErrorContext(r.Context(), "rendering error page", "err", err)
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		}
+	})
+}
+
+// ClientIP returns the IP address of the client that made r. Each of the
+// trustedHops proxies in front of the helper, like the Cloud Foundry gorouter,
+// appends the address it received the request from to X-Forwarded-For, so the
+// client is that many entries from the right. Entries further left can be set
+// by the client and are ignored. If X-Forwarded-For has too few entries, or
+// trustedHops is zero, the address of the connection is used.
+func ClientIP(r *http.Request, trustedHops int) string {
+	var hops []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		for _, ip := range strings.Split(v, ",") {
+			hops = append(hops, strings.TrimSpace(ip))
+		}
+	}
+	if trustedHops > 0 && len(hops) >= trustedHops {
+		return hops[len(hops)-trustedHops]
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
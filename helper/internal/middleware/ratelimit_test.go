@@ -0,0 +1,91 @@
+package middleware_test
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cloud-gov/csb/helper/internal/middleware"
+	"github.com/cloud-gov/csb/helper/internal/ratelimit"
+)
+
+func TestRateLimit(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	groups := []middleware.RateGroup{
+		{Name: "assets", Prefix: "/assets/"},
+		{Name: "api", Prefix: "/api/", Limiter: ratelimit.New(ratelimit.Limit{Rate: 1, Burst: 1}, 10)},
+		{Name: "docs", Prefix: "/", Limiter: ratelimit.New(ratelimit.Limit{Rate: 1, Burst: 1}, 10)},
+	}
+	h := middleware.RateLimit(ok, slog.New(slog.NewTextHandler(io.Discard, nil)), 1, groups, "/api/")
+
+	get := func(path, xff string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-Forwarded-For", xff)
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get("/", "198.51.100.1"); rec.Code != http.StatusOK {
+		t.Fatalf("first request got status %v", rec.Code)
+	}
+	rec := get("/", "198.51.100.1")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status %v, got %v", http.StatusTooManyRequests, rec.Code)
+	}
+	if ra := rec.Header().Get("Retry-After"); ra != "1" {
+		t.Errorf("expected Retry-After 1, got %q", ra)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("expected the HTML error page, got Content-Type %q", ct)
+	}
+
+	if rec := get("/", "198.51.100.2"); rec.Code != http.StatusOK {
+		t.Errorf("another client got status %v", rec.Code)
+	}
+	if rec := get("/", "spoofed, 198.51.100.1"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("client escaped the limit by adding to X-Forwarded-For, got status %v", rec.Code)
+	}
+	for range 3 {
+		if rec := get("/assets/styles.css", "198.51.100.1"); rec.Code != http.StatusOK {
+			t.Errorf("unlimited group got status %v", rec.Code)
+		}
+	}
+
+	get("/api/x", "198.51.100.1")
+	rec = get("/api/x", "198.51.100.1")
+	if ct := rec.Header().Get("Content-Type"); rec.Code != http.StatusTooManyRequests || ct != "application/json" {
+		t.Errorf("expected a JSON 429 for the API group, got status %v and Content-Type %q", rec.Code, ct)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	cases := []struct {
+		name string
+		xff  []string
+		hops int
+		want string
+	}{
+		{name: "one hop", xff: []string{"198.51.100.1"}, hops: 1, want: "198.51.100.1"},
+		{name: "client-supplied entries are ignored", xff: []string{"1.2.3.4, 198.51.100.1"}, hops: 1, want: "198.51.100.1"},
+		{name: "two hops", xff: []string{"1.2.3.4, 198.51.100.1, 10.0.0.1"}, hops: 2, want: "198.51.100.1"},
+		{name: "multiple headers", xff: []string{"1.2.3.4", "198.51.100.1"}, hops: 1, want: "198.51.100.1"},
+		{name: "too few entries", xff: []string{"198.51.100.1"}, hops: 2, want: "192.0.2.1"},
+		{name: "no trusted hops", xff: []string{"198.51.100.1"}, hops: 0, want: "192.0.2.1"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			for _, v := range tc.xff {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			if got := middleware.ClientIP(req, tc.hops); got != tc.want {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+
+	"github.com/cloud-gov/csb/helper/internal/logging"
+	"github.com/cloud-gov/csb/helper/internal/metrics"
+	"github.com/cloud-gov/csb/helper/internal/pages"
+)
+
+// Recover recovers from panics in h so one bad request can't take down the
+// connection or the process. It logs the panic and stack trace, counts it, and
+// responds 500: with JSON if the request path starts with one of apiPrefixes,
+// and with the branded HTML error page otherwise. If h already started the
+// response, it is too late for an error page, so Recover panics with
+// [http.ErrAbortHandler] to abort the connection, and the client sees a failed
+// response rather than a truncated one that looks complete.
+func Recover(h http.Handler, logger *slog.Logger, apiPrefixes ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				// Deliberate aborts are handled, without logging, by net/http.
+				panic(v)
+			}
+
+			metrics.Panics.Inc()
+			logger.ErrorContext(r.Context(), "recovered from panic in handler",
+				"panic", fmt.Sprint(v),
+				"method", r.Method,
+				"path", r.URL.Path,
+				"stack", string(debug.Stack()),
+			)
+			if sw.status != 0 {
+				panic(http.ErrAbortHandler)
+			}
+
+			id := logging.RequestID(r.Context())
+			if isAPI(r, apiPrefixes) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error":      http.StatusText(http.StatusInternalServerError),
+					"request_id": id,
+				})
+				return
+			}
+			err := pages.RenderError(w, http.StatusInternalServerError, pages.Error{
+				Site:      site(r.Context()),
+				Title:     "Something went wrong",
+				Message:   "cloud.gov encountered an unexpected error while serving this page.",
+				RequestID: id,
+			})
+			if err != nil {
+				logger.ErrorContext(r.Context(), "rendering error page", "err", err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}
+		}()
+		h.ServeHTTP(sw, r)
+	})
+}
+
+func isAPI(r *http.Request, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(r.URL.Path, p) {
+			return true
+		}
+	}
+	return false
+}
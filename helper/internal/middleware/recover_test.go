@@ -0,0 +1,165 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/cloud-gov/csb/helper/internal/logging"
+	"github.com/cloud-gov/csb/helper/internal/metrics"
+	"github.com/cloud-gov/csb/helper/internal/middleware"
+	"github.com/cloud-gov/csb/helper/internal/pages"
+)
+
+func TestRecover(t *testing.T) {
+	panicky := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("oh no")
+	})
+
+	t.Run("HTML routes get the branded error page", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := slog.New(logging.NewContextHandler(slog.NewTextHandler(&buf, nil)))
+		before := testutil.ToFloat64(metrics.Panics)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(middleware.HeaderRequestID, "req-1")
+		middleware.RequestID(middleware.Recover(panicky, logger, "/api/")).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("expected status %v, got %v", http.StatusInternalServerError, rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+			t.Errorf("expected HTML response, got Content-Type %q", ct)
+		}
+		if !strings.Contains(rec.Body.String(), "req-1") {
+			t.Errorf("expected error page to include the request ID")
+		}
+		for _, want := range []string{"oh no", "request_id=req-1", "stack="} {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("expected log to contain %q, got %q", want, buf.String())
+			}
+		}
+		if got := testutil.ToFloat64(metrics.Panics) - before; got != 1 {
+			t.Errorf("expected panic counter to increase by 1, got %v", got)
+		}
+	})
+
+	t.Run("error page has the base path and nav links", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		nav := []pages.Link{{Text: "Docs", URL: "https://cloud.gov/docs/"}}
+		h := middleware.StripBasePath(middleware.NavLinks(middleware.Recover(panicky, slog.Default(), "/api/"), nav), "/docs/")
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/docs/services/aws-ses", nil))
+
+		for _, want := range []string{`<base href="/docs/">`, `<a href="https://cloud.gov/docs/">Docs</a>`, "An official website of the United States government"} {
+			if !strings.Contains(rec.Body.String(), want) {
+				t.Errorf("expected error page to contain %q, got %v", want, rec.Body.String())
+			}
+		}
+	})
+
+	t.Run("API routes get a JSON error", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/thing", nil)
+		req.Header.Set(middleware.HeaderRequestID, "req-2")
+		middleware.RequestID(middleware.Recover(panicky, slog.Default(), "/api/")).ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusInternalServerError {
+			t.Fatalf("expected status %v, got %v", http.StatusInternalServerError, rec.Code)
+		}
+		var body map[string]string
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("expected JSON body, got %q: %v", rec.Body.String(), err)
+		}
+		if body["request_id"] != "req-2" {
+			t.Errorf("expected request_id %q, got %q", "req-2", body["request_id"])
+		}
+	})
+
+	t.Run("started responses are aborted", func(t *testing.T) {
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("partial"))
+			panic("too late")
+		})
+		var buf bytes.Buffer
+		logger := slog.New(slog.NewTextHandler(&buf, nil))
+		before := testutil.ToFloat64(metrics.Panics)
+		rec := httptest.NewRecorder()
+		defer func() {
+			if v := recover(); v != http.ErrAbortHandler {
+				t.Fatalf("expected http.ErrAbortHandler panic, got %v", v)
+			}
+			if !strings.Contains(buf.String(), "too late") {
+				t.Errorf("expected the original panic to be logged, got %q", buf.String())
+			}
+			if got := testutil.ToFloat64(metrics.Panics) - before; got != 1 {
+				t.Errorf("expected panic counter to increase by 1, got %v", got)
+			}
+			if strings.Contains(rec.Body.String(), "Something went wrong") {
+				t.Error("expected no error page after the response started")
+			}
+		}()
+		middleware.Recover(h, logger).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+
+	t.Run("aborted responses are access logged", func(t *testing.T) {
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+			panic("too late")
+		})
+		var buf bytes.Buffer
+		logger := slog.New(logging.NewContextHandler(slog.NewTextHandler(&buf, nil)))
+		req := httptest.NewRequest(http.MethodGet, "/services/aws-ses", nil)
+		req.Header.Set(middleware.HeaderRequestID, "req-3")
+		defer func() {
+			if v := recover(); v != http.ErrAbortHandler {
+				t.Fatalf("expected http.ErrAbortHandler panic, got %v", v)
+			}
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			last := lines[len(lines)-1]
+			for _, want := range []string{"msg=request", "path=/services/aws-ses", "status=200", "aborted=true", "request_id=req-3"} {
+				if !strings.Contains(last, want) {
+					t.Errorf("expected access log line to contain %q, got %q", want, last)
+				}
+			}
+		}()
+		middleware.RequestID(middleware.AccessLog(middleware.Recover(h, logger), logger)).ServeHTTP(httptest.NewRecorder(), req)
+	})
+
+	t.Run("started responses abort the connection", func(t *testing.T) {
+		srv := httptest.NewServer(middleware.Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Length", "100")
+			w.Write([]byte("partial"))
+			panic("too late")
+		}), slog.New(slog.NewTextHandler(io.Discard, nil))))
+		defer srv.Close()
+		resp, err := http.Get(srv.URL)
+		if err != nil {
+			return
+		}
+		defer resp.Body.Close()
+		if _, err := io.ReadAll(resp.Body); err == nil {
+			t.Error("expected reading the aborted response to fail")
+		}
+	})
+
+	t.Run("http.ErrAbortHandler is re-panicked", func(t *testing.T) {
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		})
+		defer func() {
+			if v := recover(); v != http.ErrAbortHandler {
+				t.Fatalf("expected http.ErrAbortHandler panic, got %v", v)
+			}
+		}()
+		middleware.Recover(h, slog.Default()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	})
+}
@@ -2,15 +2,21 @@ package middleware
 
 import (
 	"net/http"
+	"net/url"
 	"strings"
 )
 
 // RedirectHost checks if the request Host header matches `old` and redirects
-// to host `new` if so. Otherwise, the request is handled normally.
+// to host `new` if so. Otherwise, the request is handled normally. The
+// redirect keeps the path as the client sent it, so a base path removed by
+// [StripBasePath] is kept too.
 func RedirectHost(h http.Handler, old string, new string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if strings.EqualFold(r.Host, old) {
 			u := *r.URL
+			if orig, err := url.ParseRequestURI(r.RequestURI); err == nil {
+				u.Path, u.RawPath = orig.Path, orig.RawPath
+			}
 			u.Host = new
 			u.Scheme = "https"
 			http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
@@ -57,4 +57,16 @@ func TestRedirectHost(t *testing.T) {
 			t.Error("handler was not called when it should have been")
 		}
 	})
+	t.Run("keeps a stripped base path", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "http://"+oldHost+"/docs/services/aws-ses?x=1", nil)
+
+		handler := middleware.StripBasePath(middleware.RedirectHost(http.NotFoundHandler(), oldHost, newHost), "/docs/")
+		handler.ServeHTTP(rr, req)
+
+		want := fmt.Sprintf("https://%s/docs/services/aws-ses?x=1", newHost)
+		if loc := rr.Header().Get("Location"); loc != want {
+			t.Errorf("expected Location header %q, got %q", want, loc)
+		}
+	})
 }
@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/cloud-gov/csb/helper/internal/logging"
+)
+
+const (
+	// HeaderVcapRequestID is set by the Cloud Foundry gorouter on every request
+	// it routes.
+	HeaderVcapRequestID = "X-Vcap-Request-Id"
+	HeaderRequestID     = "X-Request-Id"
+)
+
+// maxRequestIDLength is the longest request ID accepted from a client.
+const maxRequestIDLength = 128
+
+// RequestID propagates the request ID from the X-Vcap-Request-Id or
+// X-Request-Id header, in that order, or assigns a new one if there is none or
+// it isn't a [validRequestID]. The ID is stored in the request context, where
+// [logging.ContextHandler] adds it to log records, and echoed in the
+// X-Request-Id response header.
+func RequestID(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(HeaderVcapRequestID)
+		if id == "" {
+			id = r.Header.Get(HeaderRequestID)
+		}
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(HeaderRequestID, id)
+		h.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
+// validRequestID reports whether id can be logged and echoed as it is: it is
+// not empty, at most maxRequestIDLength long, and only has letters, digits and
+// ".", "_", ":" and "-".
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range []byte(id) {
+		ok := 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '.' || c == '_' || c == ':' || c == '-'
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random version 4 UUID.
+func newRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	s := hex.EncodeToString(b[:])
+	return s[0:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:32]
+}
+
+// AccessLog logs the method, path, status, response size and duration of every
+// request. Wrap it with [RequestID] so the log line includes the request ID. A
+// request whose handler panics, like one [Recover] aborts after its response
+// started, is logged with aborted=true before the panic continues to net/http.
+func AccessLog(h http.Handler, logger *slog.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if sw.status == 0 && v == nil {
+				// Handlers that write nothing get an implicit 200.
+				sw.status = http.StatusOK
+			}
+			attrs := []any{
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", sw.status,
+				"bytes", sw.bytes,
+				"duration", time.Since(start),
+			}
+			if v != nil {
+				attrs = append(attrs, "aborted", true)
+			}
+			logger.InfoContext(r.Context(), "request", attrs...)
+			if v != nil {
+				panic(v)
+			}
+		}()
+		h.ServeHTTP(sw, r)
+	})
+}
@@ -0,0 +1,111 @@
+package middleware_test
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cloud-gov/csb/helper/internal/logging"
+	"github.com/cloud-gov/csb/helper/internal/middleware"
+)
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(logging.NewContextHandler(slog.NewTextHandler(&buf, nil)))
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.InfoContext(r.Context(), "from handler")
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	})
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/some/path", nil)
+	req.Header.Set(middleware.HeaderVcapRequestID, "vcap-id")
+	middleware.RequestID(middleware.AccessLog(h, logger)).ServeHTTP(rec, req)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 log lines, got %v: %q", len(lines), buf.String())
+	}
+	if !strings.Contains(lines[0], "request_id=vcap-id") {
+		t.Errorf("expected handler log line to include request ID, got %q", lines[0])
+	}
+	for _, want := range []string{"method=GET", "path=/some/path", "status=418", "bytes=15", "duration=", "request_id=vcap-id"} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("expected access log line to contain %q, got %q", want, lines[1])
+		}
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	cases := []struct {
+		Name    string
+		Headers map[string]string
+		Want    string
+		// Reject is a client-supplied ID that must not be used.
+		Reject string
+	}{
+		{
+			Name:    "propagates X-Vcap-Request-Id",
+			Headers: map[string]string{middleware.HeaderVcapRequestID: "vcap-id", middleware.HeaderRequestID: "other-id"},
+			Want:    "vcap-id",
+		},
+		{
+			Name:    "falls back to X-Request-Id",
+			Headers: map[string]string{middleware.HeaderRequestID: "other-id"},
+			Want:    "other-id",
+		},
+		{
+			Name:    "assigns an ID when none is given",
+			Headers: map[string]string{},
+		},
+		{
+			Name:    "accepts an ID of the maximum length",
+			Headers: map[string]string{middleware.HeaderRequestID: strings.Repeat("a", 128)},
+			Want:    strings.Repeat("a", 128),
+		},
+		{
+			Name:    "replaces an ID that is too long",
+			Headers: map[string]string{middleware.HeaderRequestID: strings.Repeat("a", 129)},
+			Reject:  strings.Repeat("a", 129),
+		},
+		{
+			Name:    "replaces an ID with other characters",
+			Headers: map[string]string{middleware.HeaderVcapRequestID: "id\" level=ERROR"},
+			Reject:  "id\" level=ERROR",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			var got string
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = logging.RequestID(r.Context())
+			})
+
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tc.Headers {
+				req.Header.Set(k, v)
+			}
+			middleware.RequestID(h).ServeHTTP(rec, req)
+
+			if tc.Want != "" && got != tc.Want {
+				t.Errorf("expected request ID %q, got %q", tc.Want, got)
+			}
+			if got == "" {
+				t.Error("expected a request ID in the context")
+			}
+			if tc.Reject != "" && got == tc.Reject {
+				t.Errorf("expected a new request ID, got %q", got)
+			}
+			if hdr := rec.Header().Get(middleware.HeaderRequestID); hdr != got {
+				t.Errorf("expected response header %q, got %q", got, hdr)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// SecurityPolicy is the set of security headers added to a response.
+type SecurityPolicy struct {
+	// HSTSMaxAge is how long browsers should only use HTTPS for the host. If
+	// zero, no Strict-Transport-Security header is set.
+	HSTSMaxAge time.Duration
+	// ReferrerPolicy is the value of the Referrer-Policy header. If empty, no
+	// header is set.
+	ReferrerPolicy string
+	// FrameAncestors is the CSP frame-ancestors source list, like "'none'" or
+	// "'self'". It is always enforced, even in report-only mode, and is
+	// mirrored in X-Frame-Options for older browsers.
+	FrameAncestors string
+	// CSP is the Content-Security-Policy, as directives separated by
+	// semicolons, without frame-ancestors or report-uri. If empty, only
+	// frame-ancestors is set.
+	CSP string
+	// ReportOnly sends CSP as Content-Security-Policy-Report-Only, so browsers
+	// report violations without blocking anything.
+	ReportOnly bool
+	// ReportURI, if set, is where browsers send reports of CSP violations.
+	ReportURI string
+}
+
+// DocsCSP allows the proxied broker docs and the assets injected by docproxy,
+// which are all served from the helper. Inline styles are allowed because the
+// broker's HTML uses them.
+const DocsCSP = "default-src 'self'; img-src 'self' data:; style-src 'self' 'unsafe-inline'; font-src 'self'; script-src 'self'; object-src 'none'; base-uri 'self'; form-action 'self'"
+
+// APICSP allows nothing, for routes that respond with JSON.
+const APICSP = "default-src 'none'"
+
+// headers returns the header values for p.
+func (p SecurityPolicy) headers() http.Header {
+	h := http.Header{}
+	h.Set("X-Content-Type-Options", "nosniff")
+	if p.HSTSMaxAge > 0 {
+		h.Set("Strict-Transport-Security", fmt.Sprintf("max-age=%d; includeSubDomains", int64(p.HSTSMaxAge.Seconds())))
+	}
+	if p.ReferrerPolicy != "" {
+		h.Set("Referrer-Policy", p.ReferrerPolicy)
+	}
+
+	var frameAncestors string
+	if p.FrameAncestors != "" {
+		frameAncestors = "frame-ancestors " + p.FrameAncestors
+		switch p.FrameAncestors {
+		case "'none'":
+			h.Set("X-Frame-Options", "DENY")
+		case "'self'":
+			h.Set("X-Frame-Options", "SAMEORIGIN")
+		}
+	}
+
+	directives := []string{}
+	if p.CSP != "" {
+		directives = append(directives, strings.TrimRight(strings.TrimSpace(p.CSP), ";"))
+	}
+	if p.ReportURI != "" {
+		directives = append(directives, "report-uri "+p.ReportURI)
+	}
+	if p.ReportOnly {
+		// Browsers ignore frame-ancestors in a report-only policy, so it is
+		// sent in its own enforced policy.
+		if len(directives) > 0 {
+			h.Set("Content-Security-Policy-Report-Only", strings.Join(directives, "; "))
+		}
+		if frameAncestors != "" {
+			h.Set("Content-Security-Policy", frameAncestors)
+		}
+	} else {
+		if frameAncestors != "" {
+			directives = append(directives, frameAncestors)
+		}
+		if len(directives) > 0 {
+			h.Set("Content-Security-Policy", strings.Join(directives, "; "))
+		}
+	}
+	return h
+}
+
+// SecurityHeaders adds the headers of a [SecurityPolicy] to every response.
+// Requests whose path starts with a key of overrides use that policy instead of
+// def; the longest matching prefix wins.
+func SecurityHeaders(h http.Handler, def SecurityPolicy, overrides map[string]SecurityPolicy) http.Handler {
+	defHeaders := def.headers()
+	prefixes := make(map[string]http.Header, len(overrides))
+	for prefix, p := range overrides {
+		prefixes[prefix] = p.headers()
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers, match := defHeaders, ""
+		for prefix, ph := range prefixes {
+			if strings.HasPrefix(r.URL.Path, prefix) && len(prefix) > len(match) {
+				headers, match = ph, prefix
+			}
+		}
+		for k, v := range headers {
+			w.Header()[k] = v
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,71 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cloud-gov/csb/helper/internal/middleware"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	docs := middleware.SecurityPolicy{
+		HSTSMaxAge:     24 * time.Hour,
+		ReferrerPolicy: "strict-origin-when-cross-origin",
+		FrameAncestors: "'none'",
+		CSP:            "default-src 'self'",
+		ReportOnly:     true,
+		ReportURI:      "https://reports.example.com/csp",
+	}
+	api := middleware.SecurityPolicy{FrameAncestors: "'none'", CSP: "default-src 'none'"}
+	h := middleware.SecurityHeaders(ok, docs, map[string]middleware.SecurityPolicy{
+		"/api/":       api,
+		"/api/looser": {CSP: "default-src *"},
+	})
+
+	serve := func(path string) http.Header {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Header()
+	}
+
+	t.Run("default policy in report-only mode", func(t *testing.T) {
+		got := serve("/")
+		want := map[string]string{
+			"Strict-Transport-Security":           "max-age=86400; includeSubDomains",
+			"X-Content-Type-Options":              "nosniff",
+			"Referrer-Policy":                     "strict-origin-when-cross-origin",
+			"X-Frame-Options":                     "DENY",
+			"Content-Security-Policy-Report-Only": "default-src 'self'; report-uri https://reports.example.com/csp",
+			// frame-ancestors is ignored in report-only policies, so it stays
+			// enforced.
+			"Content-Security-Policy": "frame-ancestors 'none'",
+		}
+		for k, v := range want {
+			if got.Get(k) != v {
+				t.Errorf("expected %v: %q, got %q", k, v, got.Get(k))
+			}
+		}
+	})
+
+	t.Run("override enforces CSP", func(t *testing.T) {
+		got := serve("/api/thing")
+		if csp := got.Get("Content-Security-Policy"); csp != "default-src 'none'; frame-ancestors 'none'" {
+			t.Errorf("unexpected Content-Security-Policy %q", csp)
+		}
+		if got.Get("Content-Security-Policy-Report-Only") != "" {
+			t.Error("expected no report-only policy")
+		}
+		if got.Get("Strict-Transport-Security") != "" {
+			t.Error("expected the override to replace the whole default policy, but HSTS was set")
+		}
+	})
+
+	t.Run("longest prefix wins", func(t *testing.T) {
+		if csp := serve("/api/looser/x").Get("Content-Security-Policy"); csp != "default-src *" {
+			t.Errorf("unexpected Content-Security-Policy %q", csp)
+		}
+	})
+}
@@ -0,0 +1,118 @@
+// Package osbapi reads the catalog of an Open Service Broker API broker, like
+// the Cloud Service Broker.
+package osbapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Version is the OSBAPI version the helper sends in the X-Broker-API-Version
+// header. The broker rejects requests without it.
+const Version = "2.16"
+
+// maxCatalogSize bounds how much of a catalog response is read.
+const maxCatalogSize = 10 << 20
+
+// Catalog is the response to GET /v2/catalog.
+type Catalog struct {
+	Services []Service `json:"services"`
+}
+
+// Service is a service offering.
+type Service struct {
+	ID          string          `json:"id"`
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Tags        []string        `json:"tags"`
+	Bindable    bool            `json:"bindable"`
+	Metadata    ServiceMetadata `json:"metadata"`
+	Plans       []Plan          `json:"plans"`
+}
+
+// ServiceMetadata holds the conventional metadata fields of an offering that
+// the docs show.
+type ServiceMetadata struct {
+	DisplayName         string `json:"displayName"`
+	ImageURL            string `json:"imageUrl"`
+	LongDescription     string `json:"longDescription"`
+	ProviderDisplayName string `json:"providerDisplayName"`
+	DocumentationURL    string `json:"documentationUrl"`
+	SupportURL          string `json:"supportUrl"`
+}
+
+// Plan is a plan of a service offering.
+type Plan struct {
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Free        *bool        `json:"free"`
+	Metadata    PlanMetadata `json:"metadata"`
+	Schemas     Schemas      `json:"schemas"`
+}
+
+// PlanMetadata holds the conventional metadata fields of a plan that the docs
+// show.
+type PlanMetadata struct {
+	DisplayName string   `json:"displayName"`
+	Bullets     []string `json:"bullets"`
+}
+
+// Schemas are the JSON schemas of the parameters a plan accepts.
+type Schemas struct {
+	ServiceInstance struct {
+		Create InputSchema `json:"create"`
+		Update InputSchema `json:"update"`
+	} `json:"service_instance"`
+	ServiceBinding struct {
+		Create InputSchema `json:"create"`
+	} `json:"service_binding"`
+}
+
+// InputSchema is the schema of the parameters of one operation.
+type InputSchema struct {
+	Parameters *Schema `json:"parameters"`
+}
+
+// Schema is the subset of JSON Schema that the docs show. The CSB puts a
+// parameter's "details" from the brokerpak in Description, as markdown.
+type Schema struct {
+	Type        any                `json:"type"`
+	Title       string             `json:"title"`
+	Description string             `json:"description"`
+	Properties  map[string]*Schema `json:"properties"`
+	Required    []string           `json:"required"`
+	Default     any                `json:"default"`
+	Enum        []any              `json:"enum"`
+	Pattern     string             `json:"pattern"`
+	Examples    []any              `json:"examples"`
+	Items       *Schema            `json:"items"`
+}
+
+// NewCatalogRequest builds a request for the catalog of the broker at
+// brokerURL. Only the scheme and host of brokerURL are used, since the catalog
+// is always at /v2/catalog.
+func NewCatalogRequest(ctx context.Context, brokerURL url.URL, username, password string) (*http.Request, error) {
+	u := url.URL{Scheme: brokerURL.Scheme, Host: brokerURL.Host, Path: "/v2/catalog"}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(username, password)
+	req.Header.Set("X-Broker-API-Version", Version)
+	req.Header.Set("Accept", "application/json")
+	return req, nil
+}
+
+// DecodeCatalog reads a catalog from the body of a catalog response.
+func DecodeCatalog(r io.Reader) (Catalog, error) {
+	var c Catalog
+	if err := json.NewDecoder(io.LimitReader(r, maxCatalogSize)).Decode(&c); err != nil {
+		return Catalog{}, fmt.Errorf("decoding catalog: %w", err)
+	}
+	return c, nil
+}
@@ -0,0 +1,115 @@
+package osbapi
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Parameter is one property of a plan's parameter schema.
+type Parameter struct {
+	// Name is the property name. Properties of nested objects are named by
+	// their path, like "tags.team".
+	Name string `json:"name"`
+	// Type is the JSON type, like "string", "array of string" or "string or
+	// null".
+	Type string `json:"type,omitempty"`
+	// Description is markdown.
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required"`
+	Default     any    `json:"default,omitempty"`
+	Enum        []any  `json:"enum,omitempty"`
+	Pattern     string `json:"pattern,omitempty"`
+	// Examples are sample values, from the JSON Schema "examples" keyword.
+	Examples []any `json:"examples,omitempty"`
+}
+
+// HasDefault reports whether the parameter has a default value, which may be a
+// zero value like false.
+func (p Parameter) HasDefault() bool {
+	return p.Default != nil
+}
+
+// ParameterTable is the parameters of one operation on a plan.
+type ParameterTable struct {
+	// Operation is "Provision", "Update" or "Bind".
+	Operation  string
+	Parameters []Parameter
+}
+
+// ParameterTables lists the parameters p accepts, for each operation that
+// accepts any.
+func (p Plan) ParameterTables() []ParameterTable {
+	var tables []ParameterTable
+	for _, op := range []struct {
+		name   string
+		schema *Schema
+	}{
+		{"Provision", p.Schemas.ServiceInstance.Create.Parameters},
+		{"Update", p.Schemas.ServiceInstance.Update.Parameters},
+		{"Bind", p.Schemas.ServiceBinding.Create.Parameters},
+	} {
+		if params := op.schema.Flatten(); len(params) > 0 {
+			tables = append(tables, ParameterTable{Operation: op.name, Parameters: params})
+		}
+	}
+	return tables
+}
+
+// Flatten lists the properties of s, sorted by name, with the properties of
+// nested objects after their parent. If the property has no description, its
+// title is used.
+func (s *Schema) Flatten() []Parameter {
+	return s.flatten("")
+}
+
+func (s *Schema) flatten(prefix string) []Parameter {
+	if s == nil {
+		return nil
+	}
+	var params []Parameter
+	for _, name := range slices.Sorted(maps.Keys(s.Properties)) {
+		prop := s.Properties[name]
+		if prop == nil {
+			continue
+		}
+		p := Parameter{
+			Name:        prefix + name,
+			Type:        prop.typeName(),
+			Description: prop.Description,
+			Required:    slices.Contains(s.Required, name),
+			Default:     prop.Default,
+			Enum:        prop.Enum,
+			Pattern:     prop.Pattern,
+			Examples:    prop.Examples,
+		}
+		if p.Description == "" {
+			p.Description = prop.Title
+		}
+		params = append(params, p)
+		params = append(params, prop.flatten(p.Name+".")...)
+	}
+	return params
+}
+
+// typeName describes the JSON type of s.
+func (s *Schema) typeName() string {
+	var types []string
+	switch t := s.Type.(type) {
+	case string:
+		types = []string{t}
+	case []any:
+		for _, v := range t {
+			if v, ok := v.(string); ok {
+				types = append(types, v)
+			}
+		}
+	}
+	name := strings.Join(types, " or ")
+	if name == "array" && s.Items != nil {
+		if item := s.Items.typeName(); item != "" {
+			name += " of " + item
+		}
+	}
+	return name
+}
@@ -0,0 +1,39 @@
+package osbapi_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/cloud-gov/csb/helper/internal/osbapi"
+)
+
+func TestFlatten(t *testing.T) {
+	var s osbapi.Schema
+	err := json.Unmarshal([]byte(`{
+		"type": "object",
+		"required": ["name"],
+		"properties": {
+			"tags": {"type": "object", "properties": {"team": {"type": ["string", "null"], "title": "Team"}}},
+			"name": {"type": "string", "description": "The name.", "pattern": "^[a-z]+$", "examples": ["alice", "bob"]},
+			"ips": {"type": "array", "items": {"type": "string"}, "default": []},
+			"tls": {"type": "boolean", "default": false, "enum": [true, false]}
+		}
+	}`), &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []osbapi.Parameter{
+		{Name: "ips", Type: "array of string", Default: []any{}},
+		{Name: "name", Type: "string", Description: "The name.", Required: true, Pattern: "^[a-z]+$", Examples: []any{"alice", "bob"}},
+		{Name: "tags", Type: "object"},
+		{Name: "tags.team", Type: "string or null", Description: "Team"},
+		{Name: "tls", Type: "boolean", Default: false, Enum: []any{true, false}},
+	}
+	if got := s.Flatten(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if !want[0].HasDefault() || want[1].HasDefault() || !want[4].HasDefault() {
+		t.Error("expected only parameters with a default, even a zero one, to report it")
+	}
+}
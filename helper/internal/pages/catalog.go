@@ -0,0 +1,32 @@
+package pages
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/cloud-gov/csb/helper/internal/osbapi"
+)
+
+// RenderCatalog renders the docs page for catalog: an introduction, then each
+// offering under an h1 naming it in a code element, with its plans and their
+// parameter tables. That is the structure of the broker's own docs page, so the
+// page can be split into service pages the same way.
+func RenderCatalog(catalog osbapi.Catalog) ([]byte, error) {
+	services := slices.Clone(catalog.Services)
+	slices.SortFunc(services, func(a, b osbapi.Service) int { return strings.Compare(a.Name, b.Name) })
+	var buf bytes.Buffer
+	err := templates.ExecuteTemplate(&buf, "catalog.html", struct{ Services []osbapi.Service }{services})
+	return buf.Bytes(), err
+}
+
+// jsonValue formats v as it would be written in the parameters JSON.
+func jsonValue(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
@@ -0,0 +1,132 @@
+package pages
+
+import (
+	"html/template"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	fence      = regexp.MustCompile("^\\s*```")
+	heading    = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+	listItem   = regexp.MustCompile(`^\s*([-*+]|\d+\.)\s+(.*)$`)
+	inlineCode = "`([^`]+)`"
+	link       = `\[([^\]]+)\]\(([^)\s]+)\)`
+	inlineSpan = regexp.MustCompile(inlineCode + "|" + link)
+	strong     = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	emphasis   = regexp.MustCompile(`\*([^*\s][^*]*)\*`)
+)
+
+// Markdown renders the markdown that brokerpaks use in descriptions and
+// parameter details: paragraphs, lists, fenced code blocks, headings, and
+// inline code, links, bold and *italics*. Anything else is shown as text. The
+// source is escaped, and only http, https and mailto links are kept, so a
+// broker catalog can't add markup or scripts to a page. Headings start at h4,
+// below the page's own service, plan and parameter headings.
+func Markdown(src string) template.HTML {
+	var b strings.Builder
+	var para []string
+	list := ""
+	flush := func() {
+		if len(para) > 0 {
+			b.WriteString("<p>")
+			writeInline(&b, strings.Join(para, "\n"), true)
+			b.WriteString("</p>\n")
+			para = nil
+		}
+		if list != "" {
+			b.WriteString("</" + list + ">\n")
+			list = ""
+		}
+	}
+
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case fence.MatchString(line):
+			flush()
+			b.WriteString("<pre><code>")
+			for i++; i < len(lines) && !fence.MatchString(lines[i]); i++ {
+				b.WriteString(template.HTMLEscapeString(lines[i]) + "\n")
+			}
+			b.WriteString("</code></pre>\n")
+		case strings.TrimSpace(line) == "":
+			flush()
+		case heading.MatchString(line):
+			flush()
+			m := heading.FindStringSubmatch(line)
+			level := strconv.Itoa(min(len(m[1])+3, 6))
+			b.WriteString("<h" + level + ">")
+			writeInline(&b, m[2], true)
+			b.WriteString("</h" + level + ">\n")
+		case listItem.MatchString(line):
+			m := listItem.FindStringSubmatch(line)
+			kind := "ul"
+			if strings.HasSuffix(m[1], ".") {
+				kind = "ol"
+			}
+			if len(para) > 0 || list != kind {
+				flush()
+				list = kind
+				b.WriteString("<" + kind + ">\n")
+			}
+			b.WriteString("<li>")
+			writeInline(&b, m[2], true)
+			b.WriteString("</li>\n")
+		case list != "":
+			// A line without a marker ends the list.
+			flush()
+			para = append(para, strings.TrimSpace(line))
+		default:
+			para = append(para, strings.TrimSpace(line))
+		}
+	}
+	flush()
+	return template.HTML(strings.TrimSuffix(b.String(), "\n"))
+}
+
+// writeInline writes s with code spans, links if links is set, and emphasis.
+func writeInline(b *strings.Builder, s string, links bool) {
+	last := 0
+	for _, m := range inlineSpan.FindAllStringSubmatchIndex(s, -1) {
+		writeEmphasis(b, s[last:m[0]])
+		last = m[1]
+		if m[2] >= 0 {
+			b.WriteString("<code>" + template.HTMLEscapeString(s[m[2]:m[3]]) + "</code>")
+			continue
+		}
+		text, href := s[m[4]:m[5]], s[m[6]:m[7]]
+		if !links || !safeURL(href) {
+			writeInline(b, text, false)
+			continue
+		}
+		b.WriteString(`<a href="` + template.HTMLEscapeString(href) + `">`)
+		writeInline(b, text, false)
+		b.WriteString("</a>")
+	}
+	writeEmphasis(b, s[last:])
+}
+
+func writeEmphasis(b *strings.Builder, s string) {
+	s = template.HTMLEscapeString(s)
+	s = strong.ReplaceAllString(s, "<strong>$1</strong>")
+	s = emphasis.ReplaceAllString(s, "<em>$1</em>")
+	b.WriteString(s)
+}
+
+func safeURL(ref string) bool {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return u.Host != ""
+	case "mailto":
+		return true
+	}
+	return false
+}
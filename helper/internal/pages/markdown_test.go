@@ -0,0 +1,63 @@
+package pages_test
+
+import (
+	"testing"
+
+	"github.com/cloud-gov/csb/helper/internal/pages"
+)
+
+func TestMarkdown(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "paragraphs",
+			src:  "One\nline.\n\nTwo.",
+			want: "<p>One\nline.</p>\n<p>Two.</p>",
+		},
+		{
+			name: "inline code is not formatted",
+			src:  "Set `dmarc_*_recipients` to **one** *or* more.",
+			want: "<p>Set <code>dmarc_*_recipients</code> to <strong>one</strong> <em>or</em> more.</p>",
+		},
+		{
+			name: "link",
+			src:  "See [the `SES` docs](https://docs.cloud.gov/?a=1&b=2).",
+			want: `<p>See <a href="https://docs.cloud.gov/?a=1&amp;b=2">the <code>SES</code> docs</a>.</p>`,
+		},
+		{
+			name: "unsafe link keeps only its text",
+			src:  "[click](javascript:alert)",
+			want: "<p>click</p>",
+		},
+		{
+			name: "HTML is escaped",
+			src:  `<img src=x onerror="alert(1)">`,
+			want: "<p>&lt;img src=x onerror=&#34;alert(1)&#34;&gt;</p>",
+		},
+		{
+			name: "lists",
+			src:  "Records:\n- TXT\n- MX\n1. first\n2. second",
+			want: "<p>Records:</p>\n<ul>\n<li>TXT</li>\n<li>MX</li>\n</ul>\n<ol>\n<li>first</li>\n<li>second</li>\n</ol>",
+		},
+		{
+			name: "fenced code",
+			src:  "```\n{\"domain\": \"<agency.gov>\"}\n```",
+			want: "<pre><code>{&#34;domain&#34;: &#34;&lt;agency.gov&gt;&#34;}\n</code></pre>",
+		},
+		{
+			name: "heading starts at h4",
+			src:  "# Outputs",
+			want: "<h4>Outputs</h4>",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := string(pages.Markdown(tc.src)); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+// Package pages renders the helper's own cloud.gov-branded HTML pages, like
+// error pages and the docs rendered from the broker catalog, from templates
+// compiled into the binary.
+package pages
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cloud-gov/csb/helper/internal/search"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"markdown":  Markdown,
+	"json":      jsonValue,
+	"lower":     strings.ToLower,
+	"header":    func(nav []Link, query string) header { return header{nav, query} },
+	"statusURL": func() string { return StatusURL },
+}).ParseFS(templateFS, "templates/*.html"))
+
+// StatusURL is the cloud.gov status page, where outages are announced.
+const StatusURL = "https://cloudgov.statuspage.io/"
+
+// Site is the data that every page of the helper shares.
+type Site struct {
+	// BasePath is the path the helper's pages are published under, which the
+	// page's links and assets are relative to. Defaults to "/".
+	BasePath string
+	// Nav links are listed in the cloud.gov header.
+	Nav []Link
+}
+
+// header is the data for the cloud.gov header: its links, and the query to fill
+// in its search box.
+type header struct {
+	Nav   []Link
+	Query string
+}
+
+// Error is the data for the branded error page.
+type Error struct {
+	Site
+	Title     string
+	Message   string
+	RequestID string
+	// Links are listed after the message, for places to find help.
+	Links []Link
+}
+
+// Link is a hyperlink on a page.
+type Link struct {
+	Text string
+	URL  string
+}
+
+// RenderError writes the branded error page with status code. The page is
+// rendered before anything is written, so a template error doesn't leave a
+// partial response.
+func RenderError(w http.ResponseWriter, code int, p Error) error {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, "error.html", p); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// StaleNotice renders a notice, for the top of a page served from cache, that
+// the page may be out of date because it was last fetched at fetchedAt.
+func StaleNotice(fetchedAt time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	err := templates.ExecuteTemplate(&buf, "stale-notice.html", struct {
+		FetchedAt time.Time
+		StatusURL string
+	}{fetchedAt, StatusURL})
+	return buf.Bytes(), err
+}
+
+// Search is the data for the search results page.
+type Search struct {
+	Site
+	Query   string
+	Results []search.Result
+}
+
+// RenderSearch writes the search results page.
+func RenderSearch(w http.ResponseWriter, s Search) error {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, "search.html", s); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// Chrome renders the parts of the cloud.gov layout for adding to docs pages:
+// top is the official U.S. government banner and the cloud.gov header, with nav
+// and a search box, for the start of the body; bottom is the footer, for its
+// end.
+func Chrome(nav []Link) (top, bottom []byte, err error) {
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, "banner.html", nil); err != nil {
+		return nil, nil, err
+	}
+	if err := templates.ExecuteTemplate(&buf, "header.html", header{Nav: nav}); err != nil {
+		return nil, nil, err
+	}
+	top = bytes.Clone(buf.Bytes())
+	buf.Reset()
+	if err := templates.ExecuteTemplate(&buf, "footer.html", nil); err != nil {
+		return nil, nil, err
+	}
+	return top, buf.Bytes(), nil
+}
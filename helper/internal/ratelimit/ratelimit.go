@@ -0,0 +1,136 @@
+// Package ratelimit implements per-client token bucket rate limiting with
+// bounded memory.
+package ratelimit
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit is the rate tokens are added to a bucket, and the bucket's size. The
+// zero Limit means unlimited.
+type Limit struct {
+	// Rate is in tokens per second.
+	Rate float64
+	// Burst is the most tokens a bucket holds, so the most requests a client
+	// can make at once.
+	Burst int
+}
+
+// ParseLimit parses limits like "60/m", which allows bursts of 60 requests and
+// refills at one per second. The units are s, m and h. "off" or "0" means
+// unlimited.
+func ParseLimit(s string) (Limit, error) {
+	if s == "off" || s == "0" {
+		return Limit{}, nil
+	}
+	n, unit, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("want a limit like '60/m', got '%v'", s)
+	}
+	count, err := strconv.Atoi(n)
+	if err != nil || count < 0 {
+		return Limit{}, fmt.Errorf("want a non-negative number of requests, got '%v'", n)
+	}
+	var per time.Duration
+	switch unit {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		return Limit{}, fmt.Errorf("want a unit of s, m or h, got '%v'", unit)
+	}
+	if count == 0 {
+		return Limit{}, nil
+	}
+	return Limit{Rate: float64(count) / per.Seconds(), Burst: count}, nil
+}
+
+// Unlimited reports whether l allows every request.
+func (l Limit) Unlimited() bool {
+	return l.Rate <= 0 || l.Burst <= 0
+}
+
+type bucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+}
+
+// Limiter keeps a token bucket for each key, like a client IP. To bound memory,
+// it tracks at most maxKeys buckets and evicts the least recently used. An
+// evicted client starts again with a full bucket, which only ever makes the
+// limiter more lenient.
+type Limiter struct {
+	limit   Limit
+	maxKeys int
+
+	mu      sync.Mutex
+	buckets map[string]*list.Element
+	lru     *list.List
+}
+
+// New returns a Limiter that applies limit to each key, tracking at most
+// maxKeys keys.
+func New(limit Limit, maxKeys int) *Limiter {
+	return &Limiter{
+		limit:   limit,
+		maxKeys: maxKeys,
+		buckets: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Allow takes a token from the bucket for key. If there is none, it returns
+// false and how long until there will be.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	return l.AllowAt(key, time.Now())
+}
+
+// AllowAt is [Limiter.Allow] at the time now.
+func (l *Limiter) AllowAt(key string, now time.Time) (bool, time.Duration) {
+	if l.limit.Unlimited() {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var b *bucket
+	if e, ok := l.buckets[key]; ok {
+		l.lru.MoveToFront(e)
+		b = e.Value.(*bucket)
+		elapsed := now.Sub(b.last).Seconds()
+		b.tokens = math.Min(float64(l.limit.Burst), b.tokens+elapsed*l.limit.Rate)
+		b.last = now
+	} else {
+		if l.lru.Len() >= l.maxKeys {
+			oldest := l.lru.Back()
+			l.lru.Remove(oldest)
+			delete(l.buckets, oldest.Value.(*bucket).key)
+		}
+		b = &bucket{key: key, tokens: float64(l.limit.Burst), last: now}
+		l.buckets[key] = l.lru.PushFront(b)
+	}
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / l.limit.Rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens--
+	return true, 0
+}
+
+// Len returns the number of keys being tracked.
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lru.Len()
+}
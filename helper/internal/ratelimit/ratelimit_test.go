@@ -0,0 +1,89 @@
+package ratelimit_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cloud-gov/csb/helper/internal/ratelimit"
+)
+
+func TestLimiter(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := ratelimit.New(ratelimit.Limit{Rate: 1, Burst: 2}, 100)
+
+	for i := range 2 {
+		if ok, _ := l.AllowAt("a", start); !ok {
+			t.Fatalf("request %v within burst was limited", i)
+		}
+	}
+	ok, wait := l.AllowAt("a", start)
+	if ok {
+		t.Fatal("request beyond burst was allowed")
+	}
+	if wait != time.Second {
+		t.Errorf("expected to wait 1s, got %v", wait)
+	}
+	if ok, _ := l.AllowAt("b", start); !ok {
+		t.Error("a different key was limited")
+	}
+	if ok, _ := l.AllowAt("a", start.Add(time.Second)); !ok {
+		t.Error("request after refill was limited")
+	}
+}
+
+func TestLimiterBoundsKeys(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := ratelimit.New(ratelimit.Limit{Rate: 1, Burst: 1}, 3)
+
+	l.AllowAt("first", start)
+	for i := range 10 {
+		l.AllowAt(fmt.Sprint(i), start)
+	}
+	if n := l.Len(); n != 3 {
+		t.Errorf("expected 3 tracked keys, got %v", n)
+	}
+	// "first" was evicted, so it starts over with a full bucket.
+	if ok, _ := l.AllowAt("first", start); !ok {
+		t.Error("evicted key was limited")
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    ratelimit.Limit
+		wantErr bool
+	}{
+		{in: "60/m", want: ratelimit.Limit{Rate: 1, Burst: 60}},
+		{in: "10/s", want: ratelimit.Limit{Rate: 10, Burst: 10}},
+		{in: "off", want: ratelimit.Limit{}},
+		{in: "0/m", want: ratelimit.Limit{}},
+		{in: "60", wantErr: true},
+		{in: "60/d", wantErr: true},
+		{in: "-1/s", wantErr: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := ratelimit.ParseLimit(tc.in)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error: %v, got %v", tc.wantErr, err)
+			}
+			if got != tc.want {
+				t.Errorf("expected %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func BenchmarkLimiterAllow(b *testing.B) {
+	l := ratelimit.New(ratelimit.Limit{Rate: 1000, Burst: 1000}, 10000)
+	keys := make([]string, 20000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("10.0.%d.%d", i/256, i%256)
+	}
+	b.ResetTimer()
+	for i := range b.N {
+		l.Allow(keys[i%len(keys)])
+	}
+}
@@ -0,0 +1,149 @@
+// Package reload swaps in a new handler, built from freshly loaded
+// configuration, without restarting the helper.
+package reload
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/cloud-gov/csb/helper/internal/auth"
+	"github.com/cloud-gov/csb/helper/internal/config"
+)
+
+// ErrStaticKeyChanged means the new configuration changes keys that are only
+// read at startup. Restart the helper to apply it.
+var ErrStaticKeyChanged = errors.New("keys changed that only take effect after a restart")
+
+// Reloader serves requests with a handler built from the current configuration.
+// [Reloader.Reload] re-reads the configuration and atomically replaces the
+// handler; requests already in flight finish on the old one.
+type Reloader struct {
+	logger  *slog.Logger
+	sources func() (config.Values, error)
+	build   func(config.Config) (http.Handler, error)
+
+	mu      sync.Mutex // serializes reloads
+	vals    config.Values
+	handler atomic.Pointer[http.Handler]
+}
+
+// New builds the initial handler from vals. sources is called to re-read
+// configuration on every reload, and build turns a validated configuration into
+// a handler.
+func New(logger *slog.Logger, vals config.Values, sources func() (config.Values, error), build func(config.Config) (http.Handler, error)) (*Reloader, error) {
+	c, err := config.Parse(vals)
+	if err != nil {
+		return nil, err
+	}
+	r := &Reloader{
+		logger:  logger,
+		sources: sources,
+		build:   build,
+		vals:    vals,
+	}
+	h, err := build(c)
+	if err != nil {
+		return nil, err
+	}
+	r.handler.Store(&h)
+	return r, nil
+}
+
+func (r *Reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	(*r.handler.Load()).ServeHTTP(w, req)
+}
+
+// Reload re-reads and validates the configuration and, if it is valid, swaps in
+// a handler built from it. If it is invalid, or the handler can't be built from
+// it, the current handler keeps serving and the error is returned.
+//
+// Keys marked [config.Key.Static], like PORT, are only read at startup, so a
+// configuration that changes them is rejected with [ErrStaticKeyChanged] rather
+// than partly applied.
+func (r *Reloader) Reload(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	vals, err := r.sources()
+	if err != nil {
+		return err
+	}
+	c, err := config.Parse(vals)
+	if err != nil {
+		return err
+	}
+	var changed []string
+	for _, k := range config.Keys {
+		if k.Static && vals[k.Name].Value != r.vals[k.Name].Value {
+			changed = append(changed, k.Name)
+		}
+	}
+	if len(changed) > 0 {
+		return fmt.Errorf("%w: %v", ErrStaticKeyChanged, strings.Join(changed, ", "))
+	}
+
+	h, err := r.build(c)
+	if err != nil {
+		return err
+	}
+	r.handler.Store(&h)
+	r.vals = vals
+	r.logger.InfoContext(ctx, "reloaded configuration")
+	return nil
+}
+
+// WatchSignals reloads the configuration every time the process receives
+// SIGHUP, until ctx is done.
+func (r *Reloader) WatchSignals(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			r.logger.InfoContext(ctx, "received SIGHUP, reloading configuration")
+			if err := r.Reload(ctx); err != nil {
+				r.logger.ErrorContext(ctx, "rejected configuration reload, keeping current configuration", "err", err)
+			}
+		}
+	}
+}
+
+// HandleReload reloads the configuration on request. It responds 200 on
+// success, 409 if the new configuration changes a static key, and 422 with the
+// validation errors if it is otherwise rejected. The operator who asked for the
+// reload, from the token claims in the request context, is logged.
+func (r *Reloader) HandleReload() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		actor := "unknown"
+		if c := auth.ClaimsFromContext(req.Context()); c != nil {
+			actor = c.Actor()
+		}
+		r.logger.InfoContext(req.Context(), "configuration reload requested", "actor", actor)
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := r.Reload(req.Context()); err != nil {
+			r.logger.ErrorContext(req.Context(), "rejected configuration reload, keeping current configuration", "actor", actor, "err", err)
+			code := http.StatusUnprocessableEntity
+			if errors.Is(err, ErrStaticKeyChanged) {
+				code = http.StatusConflict
+			}
+			w.WriteHeader(code)
+			json.NewEncoder(w).Encode(map[string]string{"status": "rejected", "error": fmt.Sprint(err)})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"status": "reloaded"})
+	})
+}
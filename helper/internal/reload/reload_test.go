@@ -0,0 +1,157 @@
+package reload_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloud-gov/csb/helper/internal/auth"
+	"github.com/cloud-gov/csb/helper/internal/config"
+	"github.com/cloud-gov/csb/helper/internal/reload"
+)
+
+func values(host string, port string) config.Values {
+	return config.Values{
+		"HOST":                               {Value: host},
+		"PORT":                               {Value: port},
+		"BROKER_URL":                         {Value: "csb.example.com"},
+		"CG_PLATFORM_NOTIFICATION_TOPIC_ARN": {Value: "arn:aws:sns:us-east-1:123456789012:notifications"},
+	}
+}
+
+// hostHandler builds a handler that responds with the configured host, so tests
+// can tell which configuration is serving.
+func hostHandler(c config.Config) (http.Handler, error) {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, c.Host)
+	}), nil
+}
+
+func get(t *testing.T, h http.Handler) string {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	return rec.Body.String()
+}
+
+func TestReload(t *testing.T) {
+	next := values("old.example.com", "8080")
+	sources := func() (config.Values, error) { return next, nil }
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	rl, err := reload.New(logger, next, sources, hostHandler)
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if got := get(t, rl); got != "old.example.com" {
+		t.Fatalf("expected initial handler, got %q", got)
+	}
+
+	t.Run("valid config swaps the handler", func(t *testing.T) {
+		next = values("new.example.com", "8080")
+		if err := rl.Reload(context.Background()); err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+		if got := get(t, rl); got != "new.example.com" {
+			t.Fatalf("expected reloaded handler, got %q", got)
+		}
+	})
+
+	t.Run("invalid config is rejected and the old handler keeps serving", func(t *testing.T) {
+		next = values("", "8080")
+		if err := rl.Reload(context.Background()); err == nil {
+			t.Fatal("expected non-nil error")
+		}
+		if got := get(t, rl); got != "new.example.com" {
+			t.Fatalf("expected previous handler, got %q", got)
+		}
+	})
+
+	t.Run("admin endpoint reports rejected reloads", func(t *testing.T) {
+		next = values("", "8080")
+		rec := httptest.NewRecorder()
+		rl.HandleReload().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("expected status %v, got %v", http.StatusUnprocessableEntity, rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), "invalid HOST") {
+			t.Errorf("expected validation error in body, got %q", rec.Body.String())
+		}
+	})
+
+	t.Run("admin endpoint logs the actor", func(t *testing.T) {
+		next = values("new.example.com", "8080")
+		logs.Reset()
+		req := httptest.NewRequest(http.MethodPost, "/admin/reload", nil)
+		req = req.WithContext(auth.WithClaims(req.Context(), &auth.Claims{UserName: "alice@example.com"}))
+		rec := httptest.NewRecorder()
+		rl.HandleReload().ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %v, got %v", http.StatusOK, rec.Code)
+		}
+		if !strings.Contains(logs.String(), "actor=uaa:alice@example.com") {
+			t.Errorf("expected the actor to be logged, got %q", logs.String())
+		}
+	})
+
+	t.Run("changes to static keys are rejected", func(t *testing.T) {
+		next = values("newer.example.com", "9090")
+		err := rl.Reload(context.Background())
+		if !errors.Is(err, reload.ErrStaticKeyChanged) || !strings.Contains(err.Error(), "PORT") {
+			t.Fatalf("expected an error naming PORT, got %v", err)
+		}
+		if got := get(t, rl); got != "new.example.com" {
+			t.Fatalf("expected previous handler, got %q", got)
+		}
+		rec := httptest.NewRecorder()
+		rl.HandleReload().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+		if rec.Code != http.StatusConflict {
+			t.Errorf("expected status %v, got %v", http.StatusConflict, rec.Code)
+		}
+	})
+}
+
+// TestReloadConfigFile checks that a reload picks up changes to the config
+// file, the one source that can change while the helper runs.
+func TestReloadConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	write := func(host string) {
+		t.Helper()
+		b := fmt.Sprintf(`{"HOST": %q, "PORT": "8080", "BROKER_URL": "csb.example.com", "CG_PLATFORM_NOTIFICATION_TOPIC_ARN": "arn:aws:sns:us-east-1:123456789012:notifications"}`, host)
+		if err := os.WriteFile(path, []byte(b), 0o600); err != nil {
+			t.Fatal("error writing config file. This is a problem with the test.", err)
+		}
+	}
+	for _, k := range config.Keys {
+		t.Setenv(k.Name, "")
+		os.Unsetenv(k.Name)
+	}
+	t.Setenv("VCAP_SERVICES", "")
+	t.Setenv(config.FileEnv, path)
+
+	write("old.example.com")
+	vals, err := config.Sources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rl, err := reload.New(slog.New(slog.NewTextHandler(io.Discard, nil)), vals, config.Sources, hostHandler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write("new.example.com")
+	if err := rl.Reload(context.Background()); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if got := get(t, rl); got != "new.example.com" {
+		t.Errorf("expected the handler for the changed file, got %q", got)
+	}
+}
@@ -0,0 +1,257 @@
+// Package search is an in-memory full-text index of the services reference.
+package search
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Entry is a searchable part of the docs, like an offering, a plan or a
+// parameter.
+type Entry struct {
+	// Service is the name of the offering the entry is about.
+	Service string
+	// Title is a short name for the entry, like "Plan domain" or a parameter
+	// name.
+	Title string
+	// URL links to the entry, relative to the root of the docs, like
+	// "services/aws-ses#aws-ses-plan-domain".
+	URL string
+	// Text is the entry's content, which snippets are cut from.
+	Text string
+}
+
+// Weights of a term matching each field of an entry.
+const (
+	titleWeight   = 5
+	serviceWeight = 3
+	textWeight    = 1
+)
+
+// snippetLength is about how many characters of an entry's text a snippet
+// shows.
+const snippetLength = 160
+
+// Index finds entries by the terms in them.
+type Index struct {
+	entries []Entry
+	// postings maps each term to the entries it occurs in, and the weighted
+	// number of times.
+	postings map[string]map[int]int
+	// terms is the sorted keys of postings, for prefix matches.
+	terms []string
+}
+
+// Result is an entry that matches a query.
+type Result struct {
+	Entry
+	Score int
+	// Snippet is the part of the entry's text around the first match.
+	Snippet []Fragment
+}
+
+// Fragment is part of a snippet. Match is set if the text matches a query term.
+type Fragment struct {
+	Text  string
+	Match bool
+}
+
+// New indexes entries.
+func New(entries []Entry) *Index {
+	idx := &Index{entries: entries, postings: map[string]map[int]int{}}
+	for i, e := range entries {
+		for _, f := range []struct {
+			text   string
+			weight int
+		}{{e.Title, titleWeight}, {e.Service, serviceWeight}, {e.Text, textWeight}} {
+			for _, t := range Terms(f.text) {
+				p := idx.postings[t]
+				if p == nil {
+					p = map[int]int{}
+					idx.postings[t] = p
+				}
+				p[i] += f.weight
+			}
+		}
+	}
+	idx.terms = make([]string, 0, len(idx.postings))
+	for t := range idx.postings {
+		idx.terms = append(idx.terms, t)
+	}
+	slices.Sort(idx.terms)
+	return idx
+}
+
+// Len returns the number of entries in idx.
+func (idx *Index) Len() int {
+	return len(idx.entries)
+}
+
+// Search returns up to limit entries that contain every term of query, best
+// first. An entry's score is the weighted count of its matching terms, with
+// matches in its title and service name counting more than matches in its text.
+// The last term also matches as a prefix, so results show up while a word is
+// being typed.
+func (idx *Index) Search(query string, limit int) []Result {
+	terms := Terms(query)
+	if len(terms) == 0 {
+		return nil
+	}
+	var scores map[int]int
+	for i, t := range terms {
+		matches := idx.match(t, i == len(terms)-1)
+		if scores == nil {
+			scores = matches
+			continue
+		}
+		for e := range scores {
+			if n, ok := matches[e]; ok {
+				scores[e] += n
+			} else {
+				delete(scores, e)
+			}
+		}
+	}
+
+	results := make([]Result, 0, len(scores))
+	for e, score := range scores {
+		results = append(results, Result{Entry: idx.entries[e], Score: score})
+	}
+	slices.SortFunc(results, func(a, b Result) int {
+		if c := cmp.Compare(b.Score, a.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.URL, b.URL)
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	for i := range results {
+		results[i].Snippet = snippet(results[i].Text, terms)
+	}
+	return results
+}
+
+// match returns the weighted counts of the entries that contain term, or, if
+// prefix is set, any term that starts with it.
+func (idx *Index) match(term string, prefix bool) map[int]int {
+	matches := map[int]int{}
+	if !prefix {
+		for e, n := range idx.postings[term] {
+			matches[e] = n
+		}
+		return matches
+	}
+	i, _ := slices.BinarySearch(idx.terms, term)
+	for ; i < len(idx.terms) && strings.HasPrefix(idx.terms[i], term); i++ {
+		for e, n := range idx.postings[idx.terms[i]] {
+			matches[e] += n
+		}
+	}
+	return matches
+}
+
+// Terms splits s into lowercase words. Punctuation, including the underscores
+// in parameter names, separates words, so "admin_email" is found by "email".
+func Terms(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// snippet cuts about snippetLength characters of text around the first word
+// that starts with one of terms, and marks every such word in it.
+func snippet(text string, terms []string) []Fragment {
+	words := wordSpans(text)
+	first := -1
+	for i, w := range words {
+		if matchesAny(text[w[0]:w[1]], terms) {
+			first = i
+			break
+		}
+	}
+
+	start, end := 0, len(text)
+	if first >= 0 && words[first][0] > snippetLength/3 {
+		start = wordStart(text, words, words[first][0]-snippetLength/3)
+	}
+	if end-start > snippetLength {
+		end = wordEnd(text, words, start+snippetLength)
+		if end <= start {
+			// One word is longer than a snippet, so show all of it.
+			end = len(text)
+		}
+	}
+
+	var frags []Fragment
+	if start > 0 {
+		frags = append(frags, Fragment{Text: "…"})
+	}
+	pos := start
+	for _, w := range words {
+		if w[0] < start || w[1] > end || !matchesAny(text[w[0]:w[1]], terms) {
+			continue
+		}
+		frags = append(frags, Fragment{Text: text[pos:w[0]]}, Fragment{Text: text[w[0]:w[1]], Match: true})
+		pos = w[1]
+	}
+	frags = append(frags, Fragment{Text: text[pos:end]})
+	if end < len(text) {
+		frags = append(frags, Fragment{Text: "…"})
+	}
+	return slices.DeleteFunc(frags, func(f Fragment) bool { return f.Text == "" })
+}
+
+// wordSpans returns the byte ranges of the words in text, split as by [Terms].
+func wordSpans(text string) [][2]int {
+	var spans [][2]int
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(text)})
+	}
+	return spans
+}
+
+// wordStart returns the start of the first word at or after i.
+func wordStart(text string, words [][2]int, i int) int {
+	for _, w := range words {
+		if w[0] >= i {
+			return w[0]
+		}
+	}
+	return len(text)
+}
+
+// wordEnd returns the end of the last word that ends at or before i.
+func wordEnd(text string, words [][2]int, i int) int {
+	end := 0
+	for _, w := range words {
+		if w[1] > i {
+			break
+		}
+		end = w[1]
+	}
+	return end
+}
+
+func matchesAny(word string, terms []string) bool {
+	word = strings.ToLower(word)
+	for _, t := range terms {
+		if strings.HasPrefix(word, t) {
+			return true
+		}
+	}
+	return false
+}
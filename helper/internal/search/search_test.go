@@ -0,0 +1,95 @@
+package search_test
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/cloud-gov/csb/helper/internal/search"
+)
+
+var entries = []search.Entry{
+	{Service: "aws-ses", Title: "AWS Simple Email Service aws-ses", URL: "services/aws-ses#service-aws-ses", Text: "Send email from verified domains using Amazon Simple Email Service (SES)."},
+	{Service: "aws-ses", Title: "admin_email", URL: "services/aws-ses#aws-ses-plan-domain-provision", Text: "admin_email string An administrative email address that cloud.gov will use to contact you."},
+	{Service: "aws-ses", Title: "domain", URL: "services/aws-ses#aws-ses-plan-domain-provision", Text: "domain string Domain from which mail will be sent."},
+	{Service: "aws-s3", Title: "AWS S3 aws-s3", URL: "services/aws-s3#service-aws-s3", Text: "Amazon S3 buckets."},
+}
+
+func titles(results []search.Result) []string {
+	var ts []string
+	for _, r := range results {
+		ts = append(ts, r.Title)
+	}
+	return ts
+}
+
+func TestSearch(t *testing.T) {
+	idx := search.New(entries)
+	cases := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{name: "title matches rank first", query: "email", want: []string{"admin_email", "AWS Simple Email Service aws-ses"}},
+		{name: "every term must match", query: "amazon buckets", want: []string{"AWS S3 aws-s3"}},
+		{name: "last term matches a prefix", query: "verified dom", want: []string{"AWS Simple Email Service aws-ses"}},
+		{name: "earlier terms match whole words", query: "dom mail", want: nil},
+		{name: "case and punctuation are ignored", query: "ADMIN_EMAIL", want: []string{"admin_email"}},
+		{name: "no terms", query: " ?! ", want: nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := titles(idx.Search(tc.query, 10)); !slices.Equal(got, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+
+	if got := idx.Search("aws", 2); len(got) != 2 {
+		t.Errorf("expected results to be limited to 2, got %v", len(got))
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	text := strings.Repeat("filler ", 40) + "the reputation alarm fires " + strings.Repeat("filler ", 40)
+	idx := search.New([]search.Entry{{Title: "alarms", Text: text}})
+	results := idx.Search("reputation", 1)
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %v", len(results))
+	}
+
+	var b strings.Builder
+	var marked []string
+	for _, f := range results[0].Snippet {
+		b.WriteString(f.Text)
+		if f.Match {
+			marked = append(marked, f.Text)
+		}
+	}
+	got := b.String()
+	if !strings.HasPrefix(got, "…") || !strings.HasSuffix(got, "…") {
+		t.Errorf("expected a snippet cut from the middle of the text, got %q", got)
+	}
+	if len(got) > 200 {
+		t.Errorf("expected a short snippet, got %v bytes", len(got))
+	}
+	if !slices.Equal(marked, []string{"reputation"}) {
+		t.Errorf("expected the match to be marked, got %v", marked)
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	var many []search.Entry
+	for i := range 1000 {
+		for _, e := range entries {
+			e.URL = fmt.Sprint(e.URL, i)
+			many = append(many, e)
+		}
+	}
+	idx := search.New(many)
+	b.ResetTimer()
+	for range b.N {
+		idx.Search("email dom", 20)
+	}
+}
@@ -0,0 +1,96 @@
+// Package server runs the helper's HTTP servers with timeouts and graceful
+// shutdown.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// New returns an http.Server for h with read, write and idle timeouts. The
+// write timeout leaves room for a slow upstream broker or SES call.
+func New(addr string, h http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      60 * time.Second,
+		IdleTimeout:       120 * time.Second,
+	}
+}
+
+// Serve accepts connections on ln until ctx is done, then shuts srv down.
+// In-flight requests have up to grace to finish; after that, remaining
+// connections are closed, which cancels their request contexts.
+//
+// Serve returns nil after a clean shutdown.
+func Serve(ctx context.Context, logger *slog.Logger, srv *http.Server, ln net.Listener, grace time.Duration) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errc:
+		// The server failed before it was asked to stop.
+		return err
+	case <-ctx.Done():
+	}
+
+	logger.Info("shutting down server", "addr", ln.Addr().String(), "grace", grace)
+	sctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	if err := srv.Shutdown(sctx); err != nil {
+		srv.Close()
+		return fmt.Errorf("shutting down server on %v: %w", ln.Addr(), err)
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// Run listens on the Addr of each server and serves them with [Serve]. When ctx
+// is done or any server fails, all servers are shut down. Run returns once
+// every server has stopped.
+func Run(ctx context.Context, logger *slog.Logger, grace time.Duration, servers ...*http.Server) error {
+	listeners := make([]net.Listener, 0, len(servers))
+	for _, srv := range servers {
+		ln, err := net.Listen("tcp", srv.Addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return fmt.Errorf("listening on %v: %w", srv.Addr, err)
+		}
+		listeners = append(listeners, ln)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errc := make(chan error, len(servers))
+	for i, srv := range servers {
+		ln := listeners[i]
+		go func() {
+			logger.Info("Starting server...", "addr", ln.Addr().String())
+			err := Serve(ctx, logger, srv, ln, grace)
+			if err != nil {
+				cancel()
+			}
+			errc <- err
+		}()
+	}
+
+	var errs []error
+	for range servers {
+		errs = append(errs, <-errc)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,115 @@
+package server_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cloud-gov/csb/helper/internal/server"
+)
+
+// startServer serves h on a random local port with server.Serve and returns the
+// server's URL and a channel that receives Serve's result.
+func startServer(t *testing.T, ctx context.Context, h http.Handler, grace time.Duration) (string, <-chan error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("error listening. This is a problem with the test.", err)
+	}
+	srv := server.New(ln.Addr().String(), h)
+	done := make(chan error, 1)
+	go func() {
+		done <- server.Serve(ctx, slog.New(slog.NewTextHandler(io.Discard, nil)), srv, ln, grace)
+	}()
+	return "http://" + ln.Addr().String(), done
+}
+
+func TestServe(t *testing.T) {
+	t.Run("in-flight requests finish during shutdown", func(t *testing.T) {
+		started := make(chan struct{})
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			time.Sleep(200 * time.Millisecond)
+			w.Write([]byte("done"))
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		url, done := startServer(t, ctx, h, 5*time.Second)
+
+		type result struct {
+			body string
+			err  error
+		}
+		resc := make(chan result, 1)
+		go func() {
+			resp, err := http.Get(url)
+			if err != nil {
+				resc <- result{err: err}
+				return
+			}
+			defer resp.Body.Close()
+			b, err := io.ReadAll(resp.Body)
+			resc <- result{body: string(b), err: err}
+		}()
+
+		<-started
+		cancel()
+
+		res := <-resc
+		if res.err != nil {
+			t.Fatalf("expected in-flight request to succeed, got %v", res.err)
+		}
+		if res.body != "done" {
+			t.Fatalf("expected body %q, got %q", "done", res.body)
+		}
+		if err := <-done; err != nil {
+			t.Fatalf("expected clean shutdown, got %v", err)
+		}
+	})
+
+	t.Run("requests exceeding the grace period are cut off", func(t *testing.T) {
+		started := make(chan struct{})
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-r.Context().Done()
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		url, done := startServer(t, ctx, h, 50*time.Millisecond)
+
+		go func() {
+			resp, err := http.Get(url)
+			if err == nil {
+				resp.Body.Close()
+			}
+		}()
+
+		<-started
+		cancel()
+
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Fatal("expected an error when the grace period is exceeded")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Serve did not return after the grace period")
+		}
+	})
+
+	t.Run("new connections are refused after shutdown", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		url, done := startServer(t, ctx, http.NotFoundHandler(), time.Second)
+		cancel()
+		if err := <-done; err != nil {
+			t.Fatalf("expected clean shutdown, got %v", err)
+		}
+		if resp, err := http.Get(url); err == nil {
+			resp.Body.Close()
+			t.Fatal("expected request after shutdown to fail")
+		}
+	})
+}
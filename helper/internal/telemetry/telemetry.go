@@ -0,0 +1,90 @@
+// Package telemetry configures OpenTelemetry tracing for the CSB Helper.
+//
+// Packages that create spans get their tracer from the global provider with
+// otel.Tracer, so tracing is a no-op until [Setup] installs an exporter.
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const serviceName = "csb-helper"
+
+// Setup installs a global tracer provider that exports spans over OTLP/HTTP to
+// endpoint, the full URL of the collector's traces path, like
+// "https://collector.example.com:4318/v1/traces". The path is used as given, so
+// it must include /v1/traces. Other OTEL_EXPORTER_OTLP_* environment variables,
+// like headers and timeouts, are honored by the exporter.
+//
+// If endpoint is empty, the global provider is left as the OpenTelemetry no-op
+// default. The returned function flushes and stops the exporter; it is always
+// safe to call.
+func Setup(ctx context.Context, endpoint string) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("creating OTLP trace exporter: %w", err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("creating OpenTelemetry resource: %w", err)
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// StartServerSpan starts the server span for r with tracer, continuing the
+// trace propagated in its headers. The span is named after the route pattern
+// that matched r, like "GET /services/{name}", or name if r wasn't routed by a
+// ServeMux. The caller must end the span.
+func StartServerSpan(r *http.Request, tracer trace.Tracer, name string) (context.Context, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	return tracer.Start(ctx, spanName(r, name), trace.WithSpanKind(trace.SpanKindServer))
+}
+
+// spanName returns the route pattern that matched r, with the method if the
+// pattern has none, or def if there is no pattern.
+func spanName(r *http.Request, def string) string {
+	switch {
+	case r.Pattern == "":
+		return def
+	case strings.HasPrefix(r.Pattern, "/"):
+		return r.Method + " " + r.Pattern
+	}
+	return r.Pattern
+}
+
+// RecordError marks span as failed with err. It does nothing if err is nil, so
+// it can be deferred with a named error result.
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -3,79 +3,306 @@ package main
 import (
 	"context"
 	"embed"
+	"flag"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"net/http"
 	"os"
 	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awscfg "github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/ses"
-	"github.com/aws/aws-sdk-go-v2/service/sns"
 
+	"github.com/cloud-gov/csb/helper/internal/auth"
 	"github.com/cloud-gov/csb/helper/internal/brokerpaks"
+	_ "github.com/cloud-gov/csb/helper/internal/brokerpaks/ses"
 	"github.com/cloud-gov/csb/helper/internal/config"
 	"github.com/cloud-gov/csb/helper/internal/docproxy"
+	"github.com/cloud-gov/csb/helper/internal/health"
+	"github.com/cloud-gov/csb/helper/internal/logging"
+	"github.com/cloud-gov/csb/helper/internal/metrics"
 	"github.com/cloud-gov/csb/helper/internal/middleware"
+	"github.com/cloud-gov/csb/helper/internal/pages"
+	"github.com/cloud-gov/csb/helper/internal/ratelimit"
+	"github.com/cloud-gov/csb/helper/internal/reload"
+	"github.com/cloud-gov/csb/helper/internal/server"
+	"github.com/cloud-gov/csb/helper/internal/telemetry"
 )
 
+// shutdownGracePeriod is how long in-flight requests, like SNS notifications
+// that are pausing SES sending, have to finish after SIGTERM. Cloud Foundry
+// sends SIGKILL 10 seconds after SIGTERM.
+const shutdownGracePeriod = 8 * time.Second
+
+// readinessTimeout bounds each readiness check so /readyz answers before a
+// client's health check times out.
+const readinessTimeout = 3 * time.Second
+
+// hstsMaxAge is one year, the minimum for HSTS preload lists.
+const hstsMaxAge = 365 * 24 * time.Hour
+
+// rateLimitMaxClients bounds the memory used by each rate limiter. A bucket is
+// a few dozen bytes.
+const rateLimitMaxClients = 10000
+
 //go:embed assets
 var assets embed.FS
 
-func routes(c config.Config, logger *slog.Logger, sesclient *ses.Client, snsclient *sns.Client, snsdomain string) http.Handler {
+// apiPrefixes are the path prefixes of routes that return JSON rather than
+// HTML.
+var apiPrefixes = []string{"/api/", "/brokerpaks/", "/healthz", "/readyz"}
+
+// routes builds the public handler, and returns the docs it serves. snsdomain
+// is the domain of the regional SNS endpoint, which is used unless the config
+// overrides it. prevDocs, if set, are the docs of the handler being replaced,
+// whose cache is kept if the broker hasn't changed.
+func routes(c config.Config, logger *slog.Logger, awsconfig aws.Config, snsdomain string, checks map[string]health.Check, prevDocs *docproxy.Docs) (http.Handler, *docproxy.Docs, error) {
+	if c.SNSSigningCertDomain != "" {
+		snsdomain = c.SNSSigningCertDomain
+	}
+
 	mux := http.NewServeMux()
-	mux.Handle("/", docproxy.HandleDocs(logger, c))
-	mux.Handle("/assets/", docproxy.HandleAssets(logger, assets))
-	mux.Handle("/brokerpaks/", brokerpaks.Handle(logger, sesclient, snsclient, c.PlatformNotificationsTopicARN, snsdomain))
+	bps, err := brokerpaks.Mount(mux, brokerpaks.Deps{
+		Logger:    logger,
+		Config:    c,
+		AWS:       awsconfig,
+		SNSDomain: snsdomain,
+	}, c.Brokerpaks)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The broker URL and enabled brokerpaks can change on reload, so their
+	// checks are built with the routes.
+	checks = maps.Clone(checks)
+	checks["broker"] = health.HTTPReachable(http.DefaultClient, c.BrokerURL.String())
+	for _, bp := range bps {
+		checks["brokerpak_"+bp.Name()] = bp.Health
+	}
+
+	mux.Handle("GET /healthz", health.HandleLive())
+	mux.Handle("GET /readyz", health.HandleReady(logger, checks, readinessTimeout))
+	rules, err := docproxy.LoadRules(c.DocsRulesFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	manifest, err := docproxy.LoadManifest(assets, "assets/manifest.json")
+	if err != nil {
+		return nil, nil, err
+	}
+	docs := docproxy.NewDocs(logger, c, rules, manifest)
+	docs.KeepCache(prevDocs)
+	mux.Handle("/", docs.HandleDocs())
+	mux.Handle("GET /services/{name}", docs.HandleDocs())
+	mux.Handle("GET /search", docs.HandleSearch())
+	mux.Handle("GET /api/services", docs.HandleAPI())
+	mux.Handle("GET /api/services/{name}", docs.HandleAPI())
+	assetHandler, err := docproxy.HandleAssets(logger, assets)
+	if err != nil {
+		return nil, nil, err
+	}
+	mux.Handle("GET /assets/", assetHandler)
 
 	// The CSB path /docs is routed to this app by Cloud Foundry, but the Host
 	// header is still the CSB's host. Redirect it.
-	return middleware.RedirectHost(mux, c.BrokerURL.Host, c.Host)
+	var h http.Handler = middleware.RedirectHost(mux, c.BrokerURL.Host, c.Host)
+	h = middleware.RateLimit(h, logger, c.TrustedHops, rateGroups(c), apiPrefixes...)
+	h = middleware.Recover(h, logger, apiPrefixes...)
+	h = middleware.SecurityHeaders(h, securityPolicy(c, middleware.DocsCSP), apiSecurityPolicies(c))
+	h = middleware.NavLinks(h, navLinks(c))
+	h = middleware.StripBasePath(h, c.PublicBasePath)
+	h = middleware.AccessLog(h, logger)
+	return middleware.RequestID(h), docs, nil
+}
+
+// navLinks returns the configured links for the cloud.gov header.
+func navLinks(c config.Config) []pages.Link {
+	links := make([]pages.Link, 0, len(c.NavLinks))
+	for _, l := range c.NavLinks {
+		links = append(links, pages.Link{Text: l.Text, URL: l.URL})
+	}
+	return links
+}
+
+// rateGroups returns the rate limited route groups. The upstream docs fetch
+// behind / and the broker and AWS calls behind /readyz are the expensive
+// routes; /readyz shares the docs limit, but not its limiter. Assets and the
+// liveness check are not limited, and neither are the brokerpak routes: they
+// take SNS deliveries, which all come from a few AWS addresses, and verify
+// their signatures, so a limit per client would only drop alarms. Limiter state
+// starts over when the routes are rebuilt on reload.
+func rateGroups(c config.Config) []middleware.RateGroup {
+	limiter := func(group string) *ratelimit.Limiter {
+		if l := c.RateLimits[group]; !l.Unlimited() {
+			return ratelimit.New(l, rateLimitMaxClients)
+		}
+		return nil
+	}
+	return []middleware.RateGroup{
+		{Name: "assets", Prefix: "/assets/"},
+		{Name: "health", Prefix: "/healthz"},
+		{Name: "readiness", Prefix: "/readyz", Limiter: limiter("docs")},
+		{Name: "brokerpaks", Prefix: "/brokerpaks/"},
+		{Name: "docs", Prefix: "/", Limiter: limiter("docs")},
+	}
+}
+
+// securityPolicy returns the security headers for public routes with the given
+// CSP. The CSP is report-only unless the config enforces it.
+func securityPolicy(c config.Config, csp string) middleware.SecurityPolicy {
+	return middleware.SecurityPolicy{
+		HSTSMaxAge:     hstsMaxAge,
+		ReferrerPolicy: "strict-origin-when-cross-origin",
+		FrameAncestors: "'none'",
+		CSP:            csp,
+		ReportOnly:     c.CSPReportOnly,
+		ReportURI:      c.CSPReportURI,
+	}
+}
+
+// apiSecurityPolicies overrides the docs policy for JSON routes, which load
+// nothing, so their CSP is always enforced.
+func apiSecurityPolicies(c config.Config) map[string]middleware.SecurityPolicy {
+	p := securityPolicy(c, middleware.APICSP)
+	p.ReportOnly = false
+	overrides := make(map[string]middleware.SecurityPolicy, len(apiPrefixes))
+	for _, prefix := range apiPrefixes {
+		overrides[prefix] = p
+	}
+	return overrides
+}
+
+// internalRoutes returns the handler for operator endpoints served on the
+// internal listener. The admin APIs require a UAA token with the admin scope,
+// so they are only served if verifier is not nil.
+func internalRoutes(logger *slog.Logger, rl *reload.Reloader, verifier *auth.Verifier) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", metrics.Handler())
+	if verifier != nil {
+		mux.Handle("POST /admin/reload", middleware.Authenticate(rl.HandleReload(), logger, verifier, auth.ScopeAdmin))
+	}
+
+	// Every internal response is JSON or plain text, so errors are JSON.
+	var h http.Handler = middleware.Recover(mux, logger, "/")
+	h = middleware.AccessLog(h, logger)
+	return middleware.RequestID(h)
 }
 
-// run sets up dependencies, calls route registration, and starts the server.
-// It is separate from main so it can return errors conventionally and main
-// can handle them all in one place.
-func run(ctx context.Context, out io.Writer) error {
-	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
+// serve sets up dependencies, calls route registration, and starts the server.
+func serve(ctx context.Context, out io.Writer, args []string) error {
+	flags := flag.NewFlagSet("helper serve", flag.ContinueOnError)
+	flags.SetOutput(out)
+	checkConfig := flags.Bool("check-config", false, "print the effective configuration, with secrets redacted, and exit")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *checkConfig {
+		return printConfig(out)
+	}
+
+	// Cloud Foundry sends SIGTERM when stopping an instance.
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
-	logger := slog.New(slog.NewTextHandler(out, &slog.HandlerOptions{
+	logger := slog.New(logging.NewContextHandler(slog.NewTextHandler(out, &slog.HandlerOptions{
 		Level: slog.LevelInfo,
-	}))
-	config, err := config.Load()
+	})))
+	vals, err := config.Sources()
+	if err != nil {
+		return fmt.Errorf("loading CSB Helper config: %w", err)
+	}
+	cfg, err := config.Parse(vals)
 	if err != nil {
 		return fmt.Errorf("loading CSB Helper config: %w", err)
 	}
 
+	shutdownTracing, err := telemetry.Setup(ctx, cfg.TracesEndpoint)
+	if err != nil {
+		return fmt.Errorf("setting up tracing: %w", err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Error("shutting down tracing", "err", err)
+		}
+	}()
+
 	awscfg, err := awscfg.LoadDefaultConfig(ctx)
 	if err != nil {
 		return fmt.Errorf("loading AWS config: %w", err)
 	}
 
-	sesclient := ses.NewFromConfig(awscfg)
-	snsclient := sns.NewFromConfig(awscfg)
+	snsdomain, err := resolveSNSDomain(ctx, awscfg)
+	if err != nil {
+		// Keep serving docs, but report not ready: SNS messages can't be
+		// verified without the endpoint.
+		logger.Error("failed to resolve SNS endpoint", "err", err)
+		err = fmt.Errorf("resolving SNS endpoint: %w", err)
+	} else {
+		logger.Info(fmt.Sprintf("resolved SNS endpoint domain %v", snsdomain))
+	}
 
-	snsendpoint, err := sns.NewDefaultEndpointResolverV2().ResolveEndpoint(ctx, sns.EndpointParameters{
-		Region:  aws.String(awscfg.Region),
-		UseFIPS: aws.Bool(false), // This is used to validate the domain of the SigningCertURL, which will be non-FIPS
+	checks := map[string]health.Check{
+		"aws_credentials": func(ctx context.Context) error {
+			_, err := awscfg.Credentials.Retrieve(ctx)
+			return err
+		},
+		"sns_endpoint": health.Static(err),
+	}
+
+	// The docs of the last build are kept for the next, so a reload doesn't
+	// empty the docs cache. Builds are serialized by the Reloader.
+	var docs *docproxy.Docs
+	rl, err := reload.New(logger, vals, config.Sources, func(c config.Config) (http.Handler, error) {
+		h, built, err := routes(c, logger, awscfg, snsdomain, checks, docs)
+		if err == nil {
+			docs = built
+		}
+		return h, err
 	})
 	if err != nil {
-		slog.Error("failed to resolve SNS endpoint")
+		return fmt.Errorf("loading CSB Helper config: %w", err)
+	}
+	go rl.WatchSignals(ctx)
+
+	addr := fmt.Sprintf("%v:%v", cfg.ListenAddr, cfg.Port)
+	servers := []*http.Server{server.New(addr, rl)}
+	if cfg.InternalPort != 0 {
+		internalAddr := fmt.Sprintf("%v:%v", cfg.ListenAddr, cfg.InternalPort)
+		var verifier *auth.Verifier
+		if cfg.UAA.JWKSURL != "" {
+			verifier = auth.NewVerifier(http.DefaultClient, cfg.UAA.JWKSURL, cfg.UAA.Issuer, cfg.UAA.Audience)
+		} else {
+			logger.Warn("UAA_JWKS_URL is not set, so the admin APIs are disabled; send SIGHUP to reload the configuration")
+		}
+		servers = append(servers, server.New(internalAddr, internalRoutes(logger, rl, verifier)))
 	}
-	logger.Info(fmt.Sprintf("resolved SNS endpoint %v", snsendpoint.URI))
+	return server.Run(ctx, logger, shutdownGracePeriod, servers...)
+}
 
-	mux := routes(config, logger, sesclient, snsclient, snsendpoint.URI.Host)
-	addr := fmt.Sprintf("%v:%v", config.ListenAddr, config.Port)
-	logger.Info("Starting server...")
-	return http.ListenAndServe(addr, mux)
+// printConfig writes the effective configuration and the source of each value
+// to out, then validates it.
+func printConfig(out io.Writer) error {
+	vals, err := config.Sources()
+	if err != nil {
+		return fmt.Errorf("loading CSB Helper config: %w", err)
+	}
+	for _, line := range vals.Redacted() {
+		fmt.Fprintln(out, line)
+	}
+	if _, err := config.Parse(vals); err != nil {
+		return fmt.Errorf("invalid CSB Helper config:\n%w", err)
+	}
+	fmt.Fprintln(out, "configuration is valid")
+	return nil
 }
 
 func main() {
 	ctx := context.Background()
-	err := run(ctx, os.Stdout)
+	err := run(ctx, os.Stdout, os.Args[1:])
 	if err != nil {
 		slog.Error(err.Error())
 		os.Exit(1)
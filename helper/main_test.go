@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cloud-gov/csb/helper/internal/auth"
+	"github.com/cloud-gov/csb/helper/internal/config"
+	"github.com/cloud-gov/csb/helper/internal/middleware"
+	"github.com/cloud-gov/csb/helper/internal/ratelimit"
+	"github.com/cloud-gov/csb/helper/internal/reload"
+)
+
+func TestInternalRoutes(t *testing.T) {
+	vals := config.Values{
+		"HOST":                               {Value: "services.example.com"},
+		"PORT":                               {Value: "8080"},
+		"BROKER_URL":                         {Value: "csb.example.com"},
+		"CG_PLATFORM_NOTIFICATION_TOPIC_ARN": {Value: "arn:aws:sns:us-east-1:123456789012:notifications"},
+	}
+	sources := func() (config.Values, error) { return vals, nil }
+	build := func(config.Config) (http.Handler, error) { return http.NotFoundHandler(), nil }
+
+	tests := []struct {
+		name     string
+		verifier *auth.Verifier
+		wantCode int
+	}{
+		{name: "admin APIs are not served without a verifier", wantCode: http.StatusNotFound},
+		{name: "admin APIs require a token", verifier: auth.NewVerifier(http.DefaultClient, "http://uaa.invalid/token_keys", "", ""), wantCode: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			logger := slog.New(slog.NewTextHandler(&logs, nil))
+			rl, err := reload.New(logger, vals, sources, build)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rec := httptest.NewRecorder()
+			internalRoutes(logger, rl, tt.verifier).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+			if rec.Code != tt.wantCode {
+				t.Errorf("expected status %v, got %v", tt.wantCode, rec.Code)
+			}
+			if rec.Header().Get("X-Request-Id") == "" {
+				t.Error("expected a request ID")
+			}
+			if !strings.Contains(logs.String(), "path=/admin/reload") {
+				t.Errorf("expected an access log line, got %q", logs.String())
+			}
+		})
+	}
+}
+
+func TestRateGroups(t *testing.T) {
+	c := config.Config{RateLimits: map[string]ratelimit.Limit{"docs": {Rate: 1, Burst: 1}}}
+	h := middleware.RateLimit(http.NotFoundHandler(), slog.New(slog.NewTextHandler(io.Discard, nil)), 0, rateGroups(c), apiPrefixes...)
+
+	tests := []struct {
+		name     string
+		path     string
+		wantCode int
+	}{
+		{name: "docs are limited", path: "/services/aws-ses", wantCode: http.StatusTooManyRequests},
+		{name: "SNS deliveries are not limited", path: "/brokerpaks/ses/reputation-alarm", wantCode: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rec *httptest.ResponseRecorder
+			for range 3 {
+				rec = httptest.NewRecorder()
+				h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.path, nil))
+			}
+			if rec.Code != tt.wantCode {
+				t.Errorf("expected status %v, got %v", tt.wantCode, rec.Code)
+			}
+		})
+	}
+}